@@ -0,0 +1,218 @@
+package gee
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	errBodyTooSlow  = errors.New("gee: request body is sent too slowly")
+	errBodyTooLarge = errors.New("gee: request body too large")
+)
+
+// bodyGuard replaces the request body for BodyLimit and MinReadRate, so
+// both can be set by any group and a later setting replaces an earlier one
+type bodyGuard struct {
+	c      *Context
+	body   io.ReadCloser // the body as received
+	reader io.ReadCloser // body behind http.MaxBytesReader, created on the first read
+	read   int64
+
+	limit int64 // 0 means no limit
+
+	rate   int64 // minimum bytes per second, 0 means no minimum
+	grace  time.Duration
+	start  time.Time
+	conn   net.Conn // HTTP/1.x only, nil if the server did not use ConnContext
+	stream bool     // HTTP/2 or later, the connection is shared with other requests
+}
+
+func (c *Context) bodyGuard() *bodyGuard {
+	if c.guard == nil {
+		c.guard = &bodyGuard{c: c, body: c.Req.Body}
+		c.Req.Body = c.guard
+	}
+	return c.guard
+}
+
+// deadline is when the next byte has to arrive at the minimum rate
+func (g *bodyGuard) deadline() time.Time {
+	return g.start.Add(g.grace + time.Duration(float64(g.read+1)/float64(g.rate)*float64(time.Second)))
+}
+
+func (g *bodyGuard) Read(p []byte) (int, error) {
+	if g.reader == nil {
+		g.reader = g.body
+		if g.limit > 0 {
+			// the original writer, so net/http closes the connection after the response
+			g.reader = http.MaxBytesReader(g.c.writer.ResponseWriter, g.body, g.limit)
+		}
+	}
+	var timer *time.Timer
+	if g.rate > 0 {
+		if g.conn != nil {
+			_ = g.conn.SetReadDeadline(g.deadline())
+		} else if g.stream {
+			// a deadline would cut off every stream of the connection,
+			// closing the body only ends this one
+			timer = time.AfterFunc(time.Until(g.deadline()), func() { g.body.Close() })
+		}
+	}
+
+	n, err := g.reader.Read(p)
+	g.read += int64(n)
+	expired := timer != nil && !timer.Stop()
+
+	if g.rate > 0 {
+		var netErr net.Error
+		if expired || err != nil && errors.As(err, &netErr) && netErr.Timeout() || err == nil && time.Now().After(g.deadline()) {
+			g.c.bodyTooSlow = true
+			return n, errBodyTooSlow
+		}
+		if err == io.EOF && g.conn != nil {
+			_ = g.conn.SetReadDeadline(time.Time{})
+		}
+	}
+	if err != nil && err != io.EOF && g.limit > 0 && g.read >= g.limit {
+		g.c.bodyTooLarge = true
+	}
+	return n, err
+}
+
+func (g *bodyGuard) Close() error {
+	return g.body.Close()
+}
+
+// bufferBody reads at most max bytes of the body and puts them back behind the
+// body guard, so the limits set later still apply. A longer body sets bodyTooLarge
+func (c *Context) bufferBody(max int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(c.Req.Body, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > max {
+		c.bodyTooLarge = true
+		return nil, errBodyTooLarge
+	}
+	if g, ok := c.Req.Body.(*bodyGuard); ok {
+		g.body = replayBody{bytes.NewReader(body), g.body}
+		g.reader, g.read = nil, 0
+	} else {
+		c.Req.Body = replayBody{bytes.NewReader(body), c.Req.Body}
+	}
+	return body, nil
+}
+
+// replayBody reads buffered bytes and closes the body they were read from
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// rejectBody answers 413 or 408 if the body guard failed and the handlers wrote nothing
+func (c *Context) rejectBody() {
+	if err := c.bodyError(); err != nil && !c.writer.Written() {
+		c.answerBody(err)
+	}
+}
+
+// answerBody leaves err to an ErrorHandler up the chain, without one it writes the status text
+func (c *Context) answerBody(err *HTTPError) {
+	if err.Status == http.StatusRequestTimeout || err.Status == http.StatusRequestEntityTooLarge {
+		c.SetHeader("Connection", "close")
+	}
+	if !c.bodyErrorSeen(err) {
+		c.Error(err)
+	}
+	if !c.handlesErrors {
+		c.String(err.Status, "%d %s\n", err.Status, strings.ToUpper(http.StatusText(err.Status)))
+	}
+}
+
+func (c *Context) bodyErrorSeen(err *HTTPError) bool {
+	for _, e := range c.Errors {
+		var httpErr *HTTPError
+		if errors.As(e.Err, &httpErr) && httpErr.Status == err.Status {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyError turns a failed body read into the HTTPError answered for it
+func (c *Context) bodyError() *HTTPError {
+	if c.bodyTooSlow {
+		return NewHTTPError(http.StatusRequestTimeout, "body_too_slow", errBodyTooSlow.Error())
+	}
+	if c.bodyTooLarge {
+		return NewHTTPError(http.StatusRequestEntityTooLarge, "body_too_large", "request body too large")
+	}
+	return nil
+}
+
+// BodyLimit limits the request body to n bytes, it replaces the engine default
+// and the limit of an outer group, so the innermost limit decides. A request
+// announcing a larger Content-Length than that limit is rejected with 413 before
+// the route handler runs, a body found too large while reading gets 413 as long
+// as the handler did not answer
+func BodyLimit(n int64) HandlerFunc {
+	return func(c *Context) {
+		c.limitBody(n)
+		c.Next()
+		c.rejectBody()
+	}
+}
+
+// limitBody sets the limit of the body guard, 0 means no limit
+func (c *Context) limitBody(n int64) {
+	if c.Req.Body != nil && c.Req.Body != http.NoBody {
+		c.bodyGuard().limit = n
+	}
+}
+
+// checkContentLength runs right before the route handler, when all BodyLimits
+// of the chain have set the limit in effect
+func checkContentLength(c *Context) {
+	if c.guard != nil && c.guard.limit > 0 && c.Req.ContentLength > c.guard.limit {
+		c.bodyTooLarge = true
+		c.Abort()
+		c.rejectBody()
+		return
+	}
+	c.Next()
+}
+
+// MinReadRate fails the body read of clients sending less than rate bytes per second
+// after grace, slowloris style uploads then get 408 and the connection is closed.
+// A stalled HTTP/1.x client is only cut off if the server stores connections with
+// ConnContext, as Engine.Run does, otherwise the rate is checked whenever data
+// arrives. HTTP/2 streams are cut off by a timer, the connection is left alone
+func MinReadRate(rate int64, grace time.Duration) HandlerFunc {
+	return func(c *Context) {
+		if c.Req.Body != nil && c.Req.Body != http.NoBody && rate > 0 {
+			g := c.bodyGuard()
+			g.rate, g.grace, g.start = rate, grace, time.Now()
+			if c.Req.ProtoMajor == 1 {
+				g.conn, _ = c.Req.Context().Value(connKey{}).(net.Conn)
+			} else {
+				g.stream = c.Req.ProtoMajor >= 2
+			}
+		}
+		c.Next()
+		c.rejectBody()
+	}
+}
+
+type connKey struct{}
+
+// ConnContext stores the connection in the request context, set it as
+// http.Server.ConnContext when not using Engine.Run, so MinReadRate can use read deadlines
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, conn)
+}
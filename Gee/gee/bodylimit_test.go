@@ -0,0 +1,228 @@
+package gee
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBodyLimitContentLength(t *testing.T) {
+	r := New(WithMode(TestMode))
+	reached := false
+	r.Use(BodyLimit(4))
+	r.POST("/", func(c *Context) { reached = true })
+	w := r.Perform(httptest.NewRequest("POST", "/", strings.NewReader("too long")))
+	if w.Code != http.StatusRequestEntityTooLarge || reached {
+		t.Fatalf("status = %d, reached = %v", w.Code, reached)
+	}
+}
+
+func TestBodyLimitBindJSON(t *testing.T) {
+	r := New(WithMode(TestMode))
+	r.Use(ErrorHandler(), BodyLimit(8))
+	r.POST("/", func(c *Context) {
+		var obj H
+		if c.BindJSON(&obj) == nil {
+			c.JSON(200, obj)
+		}
+	})
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"geektutu"}`))
+	req.ContentLength = -1 // chunked, only found out while reading
+	w := r.Perform(req)
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "body_too_large") {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestBodyLimitGroup(t *testing.T) {
+	r := New(WithMode(TestMode), WithMaxBodySize(4))
+	read := func(c *Context) {
+		body, err := io.ReadAll(c.Req.Body)
+		if err == nil {
+			c.String(200, "%s", body)
+		}
+	}
+	r.POST("/small", read)
+	upload := r.Group("/upload")
+	upload.Use(BodyLimit(64))
+	upload.POST("/", read)
+
+	for path, code := range map[string]int{"/small": 413, "/upload/": 200} {
+		req := httptest.NewRequest("POST", path, strings.NewReader("more than four"))
+		req.ContentLength = -1
+		if w := r.Perform(req); w.Code != code {
+			t.Fatalf("%s: status = %d, want %d", path, w.Code, code)
+		}
+	}
+}
+
+// slowReader sends one byte per delay
+type slowReader struct {
+	n     int
+	delay time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	r.n--
+	p[0] = 'x'
+	return 1, nil
+}
+
+func TestMinReadRate(t *testing.T) {
+	r := New(WithMode(TestMode))
+	r.Use(MinReadRate(100, 0))
+	r.POST("/", func(c *Context) {
+		if _, err := io.ReadAll(c.Req.Body); err == nil {
+			c.String(200, "ok")
+		}
+	})
+
+	w := r.Perform(httptest.NewRequest("POST", "/", &slowReader{n: 3, delay: 50 * time.Millisecond}))
+	if w.Code != http.StatusRequestTimeout || w.Header().Get("Connection") != "close" {
+		t.Fatalf("status = %d, Connection = %q", w.Code, w.Header().Get("Connection"))
+	}
+	if w := r.Perform(httptest.NewRequest("POST", "/", strings.NewReader("fast"))); w.Code != 200 {
+		t.Fatalf("status = %d", w.Code)
+	}
+}
+
+func TestMinReadRateStalledClient(t *testing.T) {
+	r := New(WithMode(TestMode))
+	r.Use(MinReadRate(1000, 50*time.Millisecond))
+	r.POST("/", func(c *Context) {
+		if _, err := io.ReadAll(c.Req.Body); err == nil {
+			c.String(200, "ok")
+		}
+	})
+	srv := httptest.NewUnstartedServer(r)
+	srv.Config.ConnContext = ConnContext
+	srv.Start()
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// announce a body and never send it
+	fmt.Fprint(conn, "POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 100\r\n\r\nx")
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+}
+
+// deadlineConn records the read deadlines set on it
+type deadlineConn struct {
+	net.Conn
+	deadlines int
+}
+
+func (c *deadlineConn) SetReadDeadline(t time.Time) error {
+	c.deadlines++
+	return nil
+}
+
+func TestMinReadRateHTTP2(t *testing.T) {
+	r := New(WithMode(TestMode))
+	r.Use(MinReadRate(1000, 50*time.Millisecond))
+	r.POST("/", func(c *Context) {
+		if _, err := io.ReadAll(c.Req.Body); err == nil {
+			c.String(200, "ok")
+		}
+	})
+
+	// a stream which never sends its body
+	body, _ := io.Pipe()
+	conn := &deadlineConn{}
+	req := httptest.NewRequest("POST", "/", body)
+	req.ProtoMajor, req.ProtoMinor = 2, 0
+	req = req.WithContext(ConnContext(req.Context(), conn))
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- r.Perform(req) }()
+	select {
+	case w := <-done:
+		if w.Code != http.StatusRequestTimeout {
+			t.Fatalf("status = %d", w.Code)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the stalled stream was not cut off")
+	}
+	if conn.deadlines != 0 {
+		t.Fatalf("%d read deadlines set on the shared connection", conn.deadlines)
+	}
+}
+
+func TestBodyLimitInnermostDecides(t *testing.T) {
+	r := New(WithMode(TestMode), WithMaxBodySize(4))
+	ok := func(c *Context) {
+		c.String(200, "ok")
+	}
+	r.POST("/small", ok)
+	r.POST("/route", BodyLimit(1<<20), ok)
+	upload := r.Group("/upload")
+	upload.Use(BodyLimit(1 << 20))
+	upload.POST("/", ok)
+	upload.POST("/tiny", BodyLimit(2), ok)
+
+	// real Content-Length, checked before the handler
+	for path, code := range map[string]int{"/small": 413, "/route": 200, "/upload/": 200, "/upload/tiny": 413} {
+		if w := r.Perform(httptest.NewRequest("POST", path, strings.NewReader("ten bytes!"))); w.Code != code {
+			t.Errorf("%s: status = %d, want %d", path, w.Code, code)
+		}
+	}
+}
+
+func TestMaxBodySizeGlobalMiddleware(t *testing.T) {
+	r := New(WithMode(TestMode), WithMaxBodySize(100))
+	r.Use(Idempotency())
+	r.POST("/", func(c *Context) {
+		c.String(200, "ok")
+	})
+	req := httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("x", 10<<20)))
+	req.ContentLength = -1
+	req.Header.Set(HeaderIdempotencyKey, "k1")
+	if w := r.Perform(req); w.Code != 413 {
+		t.Fatalf("status = %d, a global middleware reading the body should be limited", w.Code)
+	}
+}
+
+func TestMaxBodySizeErrorHandler(t *testing.T) {
+	r := New(WithMode(TestMode), WithMaxBodySize(4))
+	r.Use(ErrorHandler())
+	r.POST("/", func(c *Context) {
+		io.ReadAll(c.Req.Body)
+	})
+	req := httptest.NewRequest("POST", "/", strings.NewReader("more than four"))
+	req.ContentLength = -1
+	if w := r.Perform(req); w.Code != 413 || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("status = %d, Content-Type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestBodyLimitPostForm(t *testing.T) {
+	r := New(WithMode(TestMode))
+	r.POST("/", BodyLimit(8), func(c *Context) {
+		c.String(200, "name=%s", c.PostForm("name"))
+	})
+	req := httptest.NewRequest("POST", "/", strings.NewReader("name=a-name-longer-than-eight-bytes"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.ContentLength = -1
+	if w := r.Perform(req); w.Code != 413 {
+		t.Fatalf("status = %d, body = %q", w.Code, w.Body.String())
+	}
+}
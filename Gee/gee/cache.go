@@ -0,0 +1,356 @@
+package gee
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResponse is a response kept by CachePage
+type CachedResponse struct {
+	Status  int
+	Header  http.Header // only the headers set by the handlers behind CachePage
+	Body    []byte
+	Created time.Time
+	Vary    map[string]string // the request headers named by Vary, with their values
+}
+
+// CacheStore keeps the responses of CachePage, it has to be safe for concurrent use
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse, ttl time.Duration)
+	Delete(key string)
+}
+
+// MemoryCache is an in-memory CacheStore, it drops the least recently used
+// response when it holds more than its capacity
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	entries  map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	resp    *CachedResponse
+	expires time.Time
+}
+
+// NewMemoryCache creates a MemoryCache holding at most capacity responses, 0 means no bound
+func NewMemoryCache(capacity int) *MemoryCache {
+	return &MemoryCache{capacity: capacity, ll: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (m *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		m.remove(elem)
+		return nil, false
+	}
+	m.ll.MoveToFront(elem)
+	return entry.resp, true
+}
+
+func (m *MemoryCache) Set(key string, resp *CachedResponse, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := &cacheEntry{key: key, resp: resp, expires: time.Now().Add(ttl)}
+	if elem, ok := m.entries[key]; ok {
+		elem.Value = entry
+		m.ll.MoveToFront(elem)
+		return
+	}
+	m.entries[key] = m.ll.PushFront(entry)
+	for m.capacity > 0 && m.ll.Len() > m.capacity {
+		m.remove(m.ll.Back())
+	}
+}
+
+func (m *MemoryCache) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elem, ok := m.entries[key]; ok {
+		m.remove(elem)
+	}
+}
+
+// Len returns the number of responses held, expired ones included until they are looked up
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ll.Len()
+}
+
+func (m *MemoryCache) remove(elem *list.Element) {
+	m.ll.Remove(elem)
+	delete(m.entries, elem.Value.(*cacheEntry).key)
+}
+
+// captureWriter passes the response through and keeps a copy of it. Of the
+// headers it keeps the ones added or changed since before was taken, headers
+// of the outer middlewares like X-Request-ID belong to one request only
+type captureWriter struct {
+	http.ResponseWriter
+	before http.Header
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func newCaptureWriter(w http.ResponseWriter) *captureWriter {
+	return &captureWriter{ResponseWriter: w, before: w.Header().Clone()}
+}
+
+func (w *captureWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		w.header = headerChanges(w.before, w.ResponseWriter.Header())
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// headerChanges returns the headers of after which are not in before with the same values
+func headerChanges(before, after http.Header) http.Header {
+	changes := make(http.Header)
+	for name, values := range after {
+		if old, ok := before[name]; ok && equalValues(old, values) {
+			continue
+		}
+		changes[name] = append([]string(nil), values...)
+	}
+	return changes
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// cacheCall is a miss being served, the other requests for its key wait for it
+type cacheCall struct {
+	wg      sync.WaitGroup
+	waiters int             // requests waiting, under the lock of pageCache
+	resp    *CachedResponse // nil if the response may not be cached
+}
+
+// CacheConfig configures CachePageWithConfig
+type CacheConfig struct {
+	// Store keeps the responses, nil uses an in-memory LRU of 1000 responses
+	Store CacheStore
+	TTL   time.Duration
+	// KeyFunc names the cache entry of a request, nil uses the host and the request URI
+	KeyFunc func(*Context) string
+	// AllowCredentials caches requests sent with Authorization or Cookie,
+	// KeyFunc then has to tell the users apart
+	AllowCredentials bool
+}
+
+// CachePage caches the GET responses of a group or route for ttl in an in-memory
+// LRU of 1000 responses, see CachePageWithConfig
+func CachePage(ttl time.Duration, keyFunc func(*Context) string) HandlerFunc {
+	return CachePageWithConfig(CacheConfig{TTL: ttl, KeyFunc: keyFunc})
+}
+
+// CachePageWithStore is CachePage keeping the responses in store
+func CachePageWithStore(store CacheStore, ttl time.Duration, keyFunc func(*Context) string) HandlerFunc {
+	return CachePageWithConfig(CacheConfig{Store: store, TTL: ttl, KeyFunc: keyFunc})
+}
+
+// CachePageWithConfig caches the 200 responses to GET requests and replays them,
+// HEAD requests are answered from the cache too. Only the headers set behind
+// the middleware are kept. Requests sent with Cache-Control: no-store skip the
+// cache, so do requests with Authorization or Cookie unless AllowCredentials is set.
+// Responses with Cache-Control no-store or private, with cookies or with Vary: *
+// are not kept. A response with Vary is only replayed for requests with the
+// same values of the headers it names, another variant replaces it.
+// Concurrent misses for the same key run the handlers once
+func CachePageWithConfig(config CacheConfig) HandlerFunc {
+	return newPageCache(config).handle
+}
+
+// pageCache is the state of CachePageWithConfig, calls are the misses being served
+type pageCache struct {
+	config CacheConfig
+	mu     sync.Mutex
+	calls  map[string]*cacheCall
+}
+
+func newPageCache(config CacheConfig) *pageCache {
+	if config.Store == nil {
+		config.Store = NewMemoryCache(1000)
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = func(c *Context) string {
+			return c.Host() + c.Req.URL.RequestURI()
+		}
+	}
+	return &pageCache{config: config, calls: make(map[string]*cacheCall)}
+}
+
+// waiting returns how many requests wait for the miss of key being served
+func (p *pageCache) waiting(key string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if call, ok := p.calls[key]; ok {
+		return call.waiters
+	}
+	return 0
+}
+
+func (p *pageCache) handle(c *Context) {
+	config := p.config
+	store, ttl, keyFunc := config.Store, config.TTL, config.KeyFunc
+	if c.Method != http.MethodGet && c.Method != http.MethodHead ||
+		hasCacheDirective(c.Req.Header, "no-store") ||
+		!config.AllowCredentials && (c.Req.Header.Get("Authorization") != "" || c.Req.Header.Get("Cookie") != "") {
+		c.Next()
+		return
+	}
+	key := keyFunc(c)
+	if resp, ok := store.Get(key); ok && varyMatches(resp, c.Req.Header) {
+		replayCached(c, resp)
+		return
+	}
+	if c.Method == http.MethodHead {
+		c.Next()
+		return
+	}
+
+	p.mu.Lock()
+	if call, ok := p.calls[key]; ok {
+		call.waiters++
+		p.mu.Unlock()
+		call.wg.Wait()
+		if call.resp != nil && varyMatches(call.resp, c.Req.Header) {
+			replayCached(c, call.resp)
+		} else {
+			c.Next()
+		}
+		return
+	}
+	call := &cacheCall{}
+	call.wg.Add(1)
+	p.calls[key] = call
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.calls, key)
+		p.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	writer := c.Writer
+	capture := newCaptureWriter(writer)
+	c.Writer = capture
+	c.SetHeader("X-Cache", "MISS")
+	c.Next()
+	c.Writer = writer
+
+	if capture.status != http.StatusOK || !cacheable(capture.header) {
+		return
+	}
+	capture.header.Del("X-Cache")
+	call.resp = &CachedResponse{
+		Status:  capture.status,
+		Header:  capture.header,
+		Body:    capture.body.Bytes(),
+		Created: time.Now(),
+		Vary:    varyValues(capture.header, c.Req.Header),
+	}
+	store.Set(key, call.resp, ttl)
+}
+
+// varyValues records the request headers named by the Vary response header
+func varyValues(header http.Header, req http.Header) map[string]string {
+	var vary map[string]string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" {
+				if vary == nil {
+					vary = make(map[string]string)
+				}
+				vary[name] = strings.Join(req.Values(name), ",")
+			}
+		}
+	}
+	return vary
+}
+
+func varyMatches(resp *CachedResponse, req http.Header) bool {
+	for name, value := range resp.Vary {
+		if strings.Join(req.Values(name), ",") != value {
+			return false
+		}
+	}
+	return true
+}
+
+func replayCached(c *Context, resp *CachedResponse) {
+	header := c.Writer.Header()
+	for name, values := range resp.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set("X-Cache", "HIT")
+	header.Set("Age", strconv.Itoa(int(time.Since(resp.Created).Seconds())))
+	c.Status(resp.Status)
+	c.Writer.Write(resp.Body)
+	c.Abort()
+}
+
+func cacheable(header http.Header) bool {
+	for _, value := range header.Values("Vary") {
+		if strings.Contains(value, "*") {
+			return false
+		}
+	}
+	return header.Get("Set-Cookie") == "" &&
+		!hasCacheDirective(header, "no-store") && !hasCacheDirective(header, "private")
+}
+
+// hasCacheDirective reports whether the Cache-Control header contains directive,
+// eg. private in Cache-Control: private, max-age=60
+func hasCacheDirective(header http.Header, directive string) bool {
+	for _, value := range header.Values("Cache-Control") {
+		for _, d := range strings.Split(value, ",") {
+			d = strings.TrimSpace(d)
+			if i := strings.IndexByte(d, '='); i >= 0 {
+				d = d[:i]
+			}
+			if strings.EqualFold(d, directive) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package gee
+
+import (
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachePage(t *testing.T) {
+	r := New(WithMode(TestMode))
+	var calls int64
+	r.GET("/report", CachePage(time.Minute, nil), func(c *Context) {
+		n := atomic.AddInt64(&calls, 1)
+		c.SetHeader("X-Version", "1")
+		c.String(200, "report %d", n)
+	})
+
+	first := r.Perform(httptest.NewRequest("GET", "/report", nil))
+	second := r.Perform(httptest.NewRequest("GET", "/report", nil))
+	if first.Header().Get("X-Cache") != "MISS" || second.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("X-Cache = %q, %q", first.Header().Get("X-Cache"), second.Header().Get("X-Cache"))
+	}
+	if second.Body.String() != "report 1" || second.Header().Get("X-Version") != "1" || calls != 1 {
+		t.Fatalf("body = %q, calls = %d", second.Body.String(), calls)
+	}
+
+	req := httptest.NewRequest("GET", "/report", nil)
+	req.Header.Set("Cache-Control", "no-store")
+	if w := r.Perform(req); w.Body.String() != "report 2" {
+		t.Fatalf("no-store request body = %q", w.Body.String())
+	}
+	if w := r.Perform(httptest.NewRequest("GET", "/report?page=2", nil)); w.Body.String() != "report 3" {
+		t.Fatalf("other query body = %q", w.Body.String())
+	}
+}
+
+func TestCachePageNotCacheable(t *testing.T) {
+	r := New(WithMode(TestMode))
+	var calls int64
+	cache := CachePage(time.Minute, nil)
+	r.GET("/private", cache, func(c *Context) {
+		atomic.AddInt64(&calls, 1)
+		c.SetHeader("Cache-Control", "private, max-age=60")
+		c.String(200, "mine")
+	})
+	r.GET("/missing", cache, func(c *Context) {
+		atomic.AddInt64(&calls, 1)
+		c.String(404, "no")
+	})
+	for i := 0; i < 2; i++ {
+		r.Perform(httptest.NewRequest("GET", "/private", nil))
+		r.Perform(httptest.NewRequest("GET", "/missing", nil))
+	}
+	if calls != 4 {
+		t.Fatalf("calls = %d, private and 404 responses must not be cached", calls)
+	}
+}
+
+func TestCachePageCollapsesMisses(t *testing.T) {
+	r := New(WithMode(TestMode))
+	var calls int64
+	release := make(chan struct{})
+	cache := newPageCache(CacheConfig{TTL: time.Minute, KeyFunc: func(c *Context) string { return "slow" }})
+	r.GET("/slow", cache.handle, func(c *Context) {
+		atomic.AddInt64(&calls, 1)
+		<-release
+		c.String(200, "slow")
+	})
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = r.Perform(httptest.NewRequest("GET", "/slow", nil)).Body.String()
+		}(i)
+	}
+	// the handler is stuck until all the other requests wait for it
+	deadline := time.Now().Add(2 * time.Second)
+	for cache.waiting("slow") < len(bodies)-1 {
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatalf("%d requests wait for the miss, want %d", cache.waiting("slow"), len(bodies)-1)
+		}
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("calls = %d, concurrent misses should run the handler once", calls)
+	}
+	for _, body := range bodies {
+		if body != "slow" {
+			t.Fatalf("body = %q", body)
+		}
+	}
+}
+
+func TestMemoryCacheLRU(t *testing.T) {
+	m := NewMemoryCache(2)
+	m.Set("a", &CachedResponse{Body: []byte("a")}, time.Minute)
+	m.Set("b", &CachedResponse{Body: []byte("b")}, time.Minute)
+	m.Get("a")
+	m.Set("c", &CachedResponse{Body: []byte("c")}, time.Minute)
+	if _, ok := m.Get("b"); ok {
+		t.Fatal("b was least recently used and should be evicted")
+	}
+	if _, ok := m.Get("a"); !ok {
+		t.Fatal("a should be kept")
+	}
+	m.Set("d", &CachedResponse{}, -time.Second)
+	if _, ok := m.Get("d"); ok {
+		t.Fatal("expired response returned")
+	}
+}
+
+func TestCachePageKeepsRequestHeaders(t *testing.T) {
+	r := New(WithMode(TestMode))
+	r.Use(RequestID())
+	r.GET("/page", CachePage(time.Minute, nil), func(c *Context) {
+		c.SetHeader("X-Version", "1")
+		c.String(200, "page")
+	})
+
+	first := r.Perform(httptest.NewRequest("GET", "/page", nil))
+	second := r.Perform(httptest.NewRequest("GET", "/page", nil))
+	if second.Header().Get("X-Cache") != "HIT" || second.Header().Get("X-Version") != "1" {
+		t.Fatalf("headers = %v", second.Header())
+	}
+	if first.Header().Get(HeaderRequestID) == second.Header().Get(HeaderRequestID) {
+		t.Fatal("the hit replayed the request id of the first request")
+	}
+}
+
+func TestCachePageCredentials(t *testing.T) {
+	var calls int64
+	handler := func(c *Context) {
+		atomic.AddInt64(&calls, 1)
+		c.String(200, "mine")
+	}
+	r := New(WithMode(TestMode))
+	r.GET("/me", CachePage(time.Minute, nil), handler)
+	r.GET("/shared", CachePageWithConfig(CacheConfig{
+		TTL:              time.Minute,
+		KeyFunc:          func(c *Context) string { return c.Req.Header.Get("Authorization") },
+		AllowCredentials: true,
+	}), handler)
+
+	for _, path := range []string{"/me", "/me", "/shared", "/shared"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer alice")
+		r.Perform(req)
+	}
+	if calls != 3 {
+		t.Fatalf("calls = %d, credentialed requests are only cached with AllowCredentials", calls)
+	}
+}
+
+func TestCachePageVary(t *testing.T) {
+	r := New(WithMode(TestMode))
+	r.GET("/greet", CachePage(time.Minute, nil), func(c *Context) {
+		c.SetHeader("Vary", "Accept-Language")
+		if c.Req.Header.Get("Accept-Language") == "de" {
+			c.String(200, "hallo")
+			return
+		}
+		c.String(200, "hello")
+	})
+	get := func(lang string) string {
+		req := httptest.NewRequest("GET", "/greet", nil)
+		req.Header.Set("Accept-Language", lang)
+		return r.Perform(req).Body.String()
+	}
+	if get("en") != "hello" || get("de") != "hallo" || get("de") != "hallo" || get("en") != "hello" {
+		t.Fatal("a variant was replayed for another Accept-Language")
+	}
+}
@@ -0,0 +1,220 @@
+package gee
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// SetTrustedProxies sets the proxies whose forwarding headers are believed,
+// each entry is an IP or a CIDR. No proxy is trusted by default
+func (engine *Engine) SetTrustedProxies(proxies []string) error {
+	cidrs := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("gee: invalid trusted proxy %q", proxy)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			proxy = fmt.Sprintf("%s/%d", proxy, bits)
+		}
+		_, cidr, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("gee: invalid trusted proxy %q: %v", proxy, err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	engine.trustedProxies = cidrs
+	return nil
+}
+
+func (engine *Engine) isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range engine.trustedProxies {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedElement is one comma separated element of the RFC 7239 Forwarded header
+type forwardedElement struct {
+	forIP net.IP
+	proto string
+	host  string
+}
+
+// parseForwarded parses eg. for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8::1]:4711"
+func parseForwarded(header string) []forwardedElement {
+	var elements []forwardedElement
+	for _, element := range strings.Split(header, ",") {
+		var e forwardedElement
+		for _, pair := range strings.Split(element, ";") {
+			i := strings.IndexByte(pair, '=')
+			if i < 0 {
+				continue
+			}
+			key := strings.ToLower(strings.TrimSpace(pair[:i]))
+			value := strings.Trim(strings.TrimSpace(pair[i+1:]), `"`)
+			switch key {
+			case "for":
+				e.forIP = parseNodeIP(value)
+			case "proto":
+				e.proto = strings.ToLower(value)
+			case "host":
+				e.host = value
+			}
+		}
+		elements = append(elements, e)
+	}
+	return elements
+}
+
+// parseNodeIP accepts 192.0.2.43, 192.0.2.43:80, [2001:db8::1] and [2001:db8::1]:4711,
+// "unknown" and obfuscated identifiers give nil
+func parseNodeIP(node string) net.IP {
+	if ip := net.ParseIP(node); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.Trim(node, "[]"))
+}
+
+// RemoteIP returns the IP of the direct peer, which may be a proxy
+func (c *Context) RemoteIP() string {
+	ip, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		return strings.TrimSpace(c.Req.RemoteAddr)
+	}
+	return ip
+}
+
+// fromTrustedProxy reports whether the forwarding headers of the request can be believed
+func (c *Context) fromTrustedProxy() bool {
+	return c.engine != nil && c.engine.isTrustedProxy(net.ParseIP(c.RemoteIP()))
+}
+
+// headerList joins all lines of the header name, a client can send a line of its
+// own in front of the ones added by the proxies
+func (c *Context) headerList(name string) string {
+	return strings.Join(c.Req.Header.Values(name), ",")
+}
+
+// forwardedClient walks the Forwarded header from the nearest hop back to the
+// first one which is not a trusted proxy, that hop is the client. A hop hidden
+// as unknown or by an obfuscated identifier ends the walk at the trusted hop before it
+func (c *Context) forwardedClient() (forwardedElement, bool) {
+	elements := parseForwarded(c.headerList("Forwarded"))
+	for i := len(elements) - 1; i >= 0; i-- {
+		if elements[i].forIP == nil {
+			if i < len(elements)-1 {
+				return elements[i+1], true
+			}
+			return forwardedElement{}, false
+		}
+		if i == 0 || !c.engine.isTrustedProxy(elements[i].forIP) {
+			return elements[i], true
+		}
+	}
+	return forwardedElement{}, false
+}
+
+// ClientIP returns the real client IP. Forwarded, X-Forwarded-For and X-Real-IP
+// are used in this order, but only when the request comes from a trusted proxy.
+// With a Forwarded header the others are not looked at, the client may have sent them
+func (c *Context) ClientIP() string {
+	if !c.fromTrustedProxy() {
+		return c.RemoteIP()
+	}
+
+	if c.headerList("Forwarded") != "" {
+		if e, ok := c.forwardedClient(); ok {
+			return e.forIP.String()
+		}
+		return c.RemoteIP()
+	}
+
+	if ip, _ := c.forwardedFor(); ip != nil {
+		return ip.String()
+	}
+
+	if ip := parseNodeIP(strings.TrimSpace(c.Req.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return c.RemoteIP()
+}
+
+// forwardedFor walks X-Forwarded-For from the nearest hop back to the first one
+// which is not a trusted proxy, it returns the IP of that hop and how many hops
+// are behind it
+func (c *Context) forwardedFor() (net.IP, int) {
+	header := c.headerList("X-Forwarded-For")
+	if header == "" {
+		return nil, 0
+	}
+	items := strings.Split(header, ",")
+	for i := len(items) - 1; i >= 0; i-- {
+		ip := parseNodeIP(strings.TrimSpace(items[i]))
+		if ip == nil {
+			break
+		}
+		if i == 0 || !c.engine.isTrustedProxy(ip) {
+			return ip, len(items) - 1 - i
+		}
+	}
+	return nil, 0
+}
+
+// forwardedValue returns the item of the X-Forwarded-Proto like header name which
+// was added for the client hop of X-Forwarded-For, the nearest one without it
+func (c *Context) forwardedValue(name string) string {
+	header := c.headerList(name)
+	if header == "" {
+		return ""
+	}
+	items := strings.Split(header, ",")
+	_, behind := c.forwardedFor()
+	i := len(items) - 1 - behind
+	if i < 0 {
+		i = 0
+	}
+	return strings.TrimSpace(items[i])
+}
+
+// Scheme returns the scheme the client used, http or https
+func (c *Context) Scheme() string {
+	if c.fromTrustedProxy() {
+		if e, ok := c.forwardedClient(); ok && e.proto != "" {
+			return e.proto
+		}
+		if proto := c.forwardedValue("X-Forwarded-Proto"); proto != "" {
+			return strings.ToLower(proto)
+		}
+	}
+	if c.Req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Host returns the host the client asked for
+func (c *Context) Host() string {
+	if c.fromTrustedProxy() {
+		if e, ok := c.forwardedClient(); ok && e.host != "" {
+			return e.host
+		}
+		if host := c.forwardedValue("X-Forwarded-Host"); host != "" {
+			return host
+		}
+	}
+	return c.Req.Host
+}
@@ -0,0 +1,103 @@
+package gee
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func newClientIPContext(t *testing.T, remoteAddr string, headers map[string]string) *Context {
+	engine := New()
+	if err := engine.SetTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = remoteAddr
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return engine.NewContext(httptest.NewRecorder(), req)
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"203.0.113.9:1234", map[string]string{"X-Forwarded-For": "1.1.1.1"}, "203.0.113.9"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.1.1.1, 2.2.2.2, 10.0.0.2"}, "2.2.2.2"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"192.168.1.1:1234", map[string]string{"X-Real-IP": "3.3.3.3"}, "3.3.3.3"},
+		{"10.0.0.1:1234", map[string]string{
+			"Forwarded":       `for="[2001:db8::1]:4711";proto=https, for=10.0.0.5`,
+			"X-Forwarded-For": "4.4.4.4",
+		}, "2001:db8::1"},
+		{"10.0.0.1:1234", nil, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		c := newClientIPContext(t, tt.remote, tt.headers)
+		if got := c.ClientIP(); got != tt.want {
+			t.Errorf("ClientIP() with %s %v = %s, want %s", tt.remote, tt.headers, got, tt.want)
+		}
+	}
+}
+
+func TestSchemeAndHost(t *testing.T) {
+	c := newClientIPContext(t, "10.0.0.1:1234", map[string]string{
+		"Forwarded": "for=5.5.5.5;proto=https;host=api.example.com, for=10.0.0.9",
+	})
+	if c.Scheme() != "https" || c.Host() != "api.example.com" {
+		t.Fatalf("got %s %s", c.Scheme(), c.Host())
+	}
+
+	c = newClientIPContext(t, "203.0.113.9:1234", map[string]string{
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "evil.example.com",
+	})
+	if c.Scheme() != "http" || c.Host() != "example.com" {
+		t.Fatalf("untrusted headers used: %s %s", c.Scheme(), c.Host())
+	}
+}
+
+func TestForwardingHeaderLines(t *testing.T) {
+	// the client sends the first line, the trusted proxies add the second one
+	c := newClientIPContext(t, "10.0.0.1:1234", nil)
+	c.Req.Header.Add("X-Forwarded-For", "6.6.6.6")
+	c.Req.Header.Add("X-Forwarded-For", "7.7.7.7, 10.0.0.2")
+	c.Req.Header.Add("X-Forwarded-Proto", "http")
+	c.Req.Header.Add("X-Forwarded-Proto", "https, https")
+	c.Req.Header.Add("X-Forwarded-Host", "evil.example.com")
+	c.Req.Header.Add("X-Forwarded-Host", "api.example.com, internal")
+	if c.ClientIP() != "7.7.7.7" || c.Scheme() != "https" || c.Host() != "api.example.com" {
+		t.Fatalf("got %s %s %s", c.ClientIP(), c.Scheme(), c.Host())
+	}
+
+	c = newClientIPContext(t, "10.0.0.1:1234", nil)
+	c.Req.Header.Add("Forwarded", "for=6.6.6.6")
+	c.Req.Header.Add("Forwarded", "for=7.7.7.7, for=10.0.0.2")
+	if c.ClientIP() != "7.7.7.7" {
+		t.Fatalf("Forwarded lines: got %s", c.ClientIP())
+	}
+
+	// a hidden hop must not hand the decision to a header of the client
+	for forwarded, want := range map[string]string{
+		"for=unknown":                 "10.0.0.1",
+		"for=_hidden, for=10.0.0.2":   "10.0.0.2",
+		`for=unknown, for="10.0.0.3"`: "10.0.0.3",
+	} {
+		c = newClientIPContext(t, "10.0.0.1:1234", map[string]string{
+			"Forwarded":       forwarded,
+			"X-Forwarded-For": "6.6.6.6",
+			"X-Real-IP":       "6.6.6.6",
+		})
+		if got := c.ClientIP(); got != want {
+			t.Errorf("Forwarded: %s: ClientIP() = %s, want %s", forwarded, got, want)
+		}
+	}
+}
+
+func TestSetTrustedProxiesInvalid(t *testing.T) {
+	if err := New().SetTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Fatal("invalid proxy should fail")
+	}
+}
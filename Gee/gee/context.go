@@ -1,10 +1,13 @@
 package gee
 
 import (
-	"encoding/json"
+	"bytes"
+	"errors"
 	"fmt"
-	"log"
+	"html/template"
+	"math"
 	"net/http"
+	"strconv"
 )
 
 type H map[string]interface{}
@@ -19,9 +22,12 @@ type Context struct {
 	Path   string
 	Method string
 	Params map[string]string
+	// the matched route pattern, eg. /hello/:name
+	fullPath string
 
 	// response info
 	StatusCode int
+	writer     *responseWriter // Writer as created, it keeps the written status and size
 
 	// middleware
 	handlers []HandlerFunc
@@ -31,6 +37,19 @@ type Context struct {
 
 	// engine pointer
 	engine *Engine
+
+	// values shared between the handlers of one request
+	Keys map[string]interface{}
+
+	// errors reported by the handlers through Error
+	Errors []*Error
+
+	// request body guard of BodyLimit and MinReadRate
+	guard        *bodyGuard
+	bodyTooLarge bool
+	bodyTooSlow  bool
+
+	handlesErrors bool // an ErrorHandler is in the chain
 }
 
 func (c *Context) Param(key string) string {
@@ -38,9 +57,46 @@ func (c *Context) Param(key string) string {
 	return value
 }
 
+// ParamInt returns the param as an int, it goes well with a :name<int> constraint
+func (c *Context) ParamInt(key string) (int, error) {
+	return strconv.Atoi(c.Param(key))
+}
+
+// ParamInt64 returns the param as an int64
+func (c *Context) ParamInt64(key string) (int64, error) {
+	return strconv.ParseInt(c.Param(key), 10, 64)
+}
+
+// FullPath returns the pattern of the matched route, or "" if no route matched
+func (c *Context) FullPath() string {
+	return c.fullPath
+}
+
+// Set stores a value for the rest of the handler chain
+func (c *Context) Set(key string, value interface{}) {
+	if c.Keys == nil {
+		c.Keys = make(map[string]interface{})
+	}
+	c.Keys[key] = value
+}
+
+// Get returns the value stored by Set
+func (c *Context) Get(key string) (value interface{}, exists bool) {
+	value, exists = c.Keys[key]
+	return
+}
+
+// GetString returns the value stored by Set if it is a string
+func (c *Context) GetString(key string) string {
+	s, _ := c.Keys[key].(string)
+	return s
+}
+
 func newContext(w http.ResponseWriter, req *http.Request) *Context {
+	writer := newResponseWriter(w)
 	return &Context{
-		Writer: w,
+		Writer: writer,
+		writer: writer,
 		Req:    req,
 		Path:   req.URL.Path,
 		Method: req.Method,
@@ -48,7 +104,6 @@ func newContext(w http.ResponseWriter, req *http.Request) *Context {
 	}
 }
 
-
 func (c *Context) Next() {
 	// index is used to record current position of the handler queue
 	// that means we can call Next() directly in some middleware method
@@ -62,8 +117,36 @@ func (c *Context) Next() {
 	}
 }
 
+// abortIndex is beyond any handler chain, so Next stops once index is set to it
+const abortIndex int = math.MaxInt32 / 2
+
+// Abort prevents the remaining handlers from being called, the current one still runs to its end
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// IsAborted reports whether Abort was called
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// AbortWithStatus writes the status and aborts
+func (c *Context) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Abort()
+}
+
+// PostForm returns the form value of key. A body over the limit or sent too
+// slowly is answered with 413 or 408 and the chain is aborted, the value is empty
 func (c *Context) PostForm(key string) string {
-	return c.Req.FormValue(key)
+	value := c.Req.FormValue(key)
+	if err := c.bodyError(); err != nil {
+		if !c.writer.Written() {
+			c.answerBody(err)
+		}
+		c.Abort()
+	}
+	return value
 }
 
 func (c *Context) Query(key string) string {
@@ -75,6 +158,12 @@ func (c *Context) Status(code int) {
 	c.Writer.WriteHeader(code)
 }
 
+// Redirect sends the client to location, eg. a path built by Engine.URL
+func (c *Context) Redirect(code int, location string) {
+	c.StatusCode = code
+	http.Redirect(c.Writer, c.Req, location, code)
+}
+
 func (c *Context) SetHeader(key string, value string) {
 	c.Writer.Header().Set(key, value)
 }
@@ -88,17 +177,41 @@ func (c *Context) String(code int, format string, values ...interface{}) {
 func (c *Context) JSON(code int, obj interface{}) {
 	c.SetHeader("Content-Type", "application/json")
 
-	c.Status(code)
-	encoder := json.NewEncoder(c.Writer)
-	if err := encoder.Encode(obj); err != nil {
+	data, err := c.engine.json.Marshal(obj)
+	if err != nil {
 		http.Error(c.Writer, err.Error(), 500)
+		return
 	}
+	c.Status(code)
+	// keep the trailing newline of json.Encoder
+	c.Writer.Write(append(data, '\n'))
 }
 
-func (c *Context) HTML(code int, name string, data interface{}){
+// HTML renders into a buffer first, so a template error never leaves a half written page
+func (c *Context) HTML(code int, name string, data interface{}) {
+	if c.engine.html == nil {
+		c.htmlError(errors.New("gee: no templates loaded"))
+		return
+	}
+	buf := new(bytes.Buffer)
+	if err := c.engine.html.execute(buf, name, c.withCSPNonce(data), c.engine.isDebug()); err != nil {
+		c.htmlError(err)
+		return
+	}
 	c.SetHeader("Content-Type", "text/html")
 	c.Status(code)
-	if err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, name, data); err != nil{
-		log.Println(500, err.Error())
+	c.Writer.Write(buf.Bytes())
+}
+
+// htmlError shows the template error in the browser in debug mode and only logs it otherwise
+func (c *Context) htmlError(err error) {
+	c.engine.logger.Printf("%d %s", http.StatusInternalServerError, err.Error())
+	if !c.engine.isDebug() {
+		c.Status(http.StatusInternalServerError)
+		return
 	}
-}
\ No newline at end of file
+	c.SetHeader("Content-Type", "text/html")
+	c.Status(http.StatusInternalServerError)
+	fmt.Fprintf(c.Writer, "<html><body><h1>Template Error</h1><pre>%s</pre></body></html>",
+		template.HTMLEscapeString(err.Error()))
+}
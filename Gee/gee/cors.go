@@ -0,0 +1,145 @@
+package gee
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures the CORS middleware
+type CORSConfig struct {
+	// AllowOrigins are exact origins like https://example.com, wildcard
+	// subdomains like https://*.example.com, or "*" for any origin
+	AllowOrigins []string
+	// AllowOriginFunc is asked when no entry of AllowOrigins matches
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods are answered to preflights, empty means the methods registered for the path
+	AllowMethods []string
+	// AllowHeaders are answered to preflights, empty means the headers the browser asked for
+	AllowHeaders []string
+	// ExposeHeaders can be read by the browser script
+	ExposeHeaders []string
+	// AllowCredentials lets the browser send cookies, the origin is then echoed instead of "*".
+	// It cannot be combined with "*" in AllowOrigins, use AllowOriginFunc to allow any origin
+	AllowCredentials bool
+	// MaxAge is how long the browser caches a preflight answer
+	MaxAge time.Duration
+}
+
+func (config *CORSConfig) allowOrigin(origin string) bool {
+	for _, allowed := range config.AllowOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if i := strings.Index(allowed, "*"); i >= 0 {
+			prefix, suffix := allowed[:i], allowed[i+1:]
+			if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				// the wildcard only stands for subdomain labels
+				if sub := origin[len(prefix) : len(origin)-len(suffix)]; !strings.ContainsAny(sub, "/:") {
+					return true
+				}
+			}
+		}
+	}
+	return config.AllowOriginFunc != nil && config.AllowOriginFunc(origin)
+}
+
+func (config *CORSConfig) anyOrigin() bool {
+	for _, allowed := range config.AllowOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS adds the CORS headers for allowed origins and answers preflight requests
+// itself, so no OPTIONS route is needed. It should be a global middleware,
+// or a middleware of the group whose routes are called from other origins.
+// CORS panics if "*" is allowed together with credentials
+func CORS(config CORSConfig) HandlerFunc {
+	if config.AllowCredentials && config.anyOrigin() {
+		panic(`gee: CORS with AllowCredentials cannot allow the origin "*", use AllowOriginFunc`)
+	}
+	allowMethods := strings.Join(config.AllowMethods, ", ")
+	allowHeaders := strings.Join(config.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(config.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge / time.Second))
+
+	return func(c *Context) {
+		origin := c.Req.Header.Get("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+
+		preflight := c.Method == http.MethodOptions && c.Req.Header.Get("Access-Control-Request-Method") != ""
+		if !config.allowOrigin(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if config.anyOrigin() {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if exposeHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			c.Next()
+			return
+		}
+
+		methods := allowMethods
+		if methods == "" {
+			r, _, _ := c.engine.routerFor(c)
+			registered := r.snapshot().allowedMethods(c.Path, c.engine.StrictPath)
+			if len(registered) == 0 {
+				// no route at all, let the NoRoute handlers answer
+				c.Next()
+				return
+			}
+			methods = strings.Join(registered, ", ")
+		}
+		if !containsToken(methods, c.Req.Header.Get("Access-Control-Request-Method")) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		header.Set("Access-Control-Allow-Methods", methods)
+		if allowHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+		} else if requested := c.Req.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+		if config.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// containsToken reports whether the comma separated list contains token, ignoring case
+func containsToken(list string, token string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package gee
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newCORSTestEngine(config CORSConfig) *Engine {
+	r := New()
+	r.Use(CORS(config))
+	r.GET("/users/:id", func(c *Context) {
+		c.String(200, "user")
+	})
+	r.DELETE("/users/:id", func(c *Context) {
+		c.String(200, "deleted")
+	})
+	return r
+}
+
+func preflight(r *Engine, origin, path, method string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("OPTIONS", path, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	req.Header.Set("Access-Control-Request-Headers", "X-Token")
+	return r.Perform(req)
+}
+
+func TestCORSPreflightUsesRegisteredMethods(t *testing.T) {
+	r := newCORSTestEngine(CORSConfig{
+		AllowOrigins: []string{"https://*.example.com"},
+		MaxAge:       time.Hour,
+	})
+	w := preflight(r, "https://app.example.com", "/users/1", "DELETE")
+	if w.Code != 204 {
+		t.Fatalf("status = %d, want 204", w.Code)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": "DELETE, GET",
+		"Access-Control-Allow-Headers": "X-Token",
+		"Access-Control-Max-Age":       "3600",
+	}
+	for key, value := range want {
+		if got := w.Header().Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+
+	if w := preflight(r, "https://app.example.com", "/users/1", "PUT"); w.Code != 403 {
+		t.Fatalf("PUT is not registered, status = %d, want 403", w.Code)
+	}
+	if w := preflight(r, "https://example.com.evil.org", "/users/1", "GET"); w.Code != 403 {
+		t.Fatalf("foreign origin status = %d, want 403", w.Code)
+	}
+	if w := preflight(r, "https://app.example.com", "/nothing", "GET"); w.Code != 404 {
+		t.Fatalf("unknown path status = %d, want 404", w.Code)
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	r := newCORSTestEngine(CORSConfig{
+		AllowOrigins:     []string{"https://a.com"},
+		AllowOriginFunc:  func(origin string) bool { return origin == "https://b.com" },
+		ExposeHeaders:    []string{"X-Total"},
+		AllowCredentials: true,
+	})
+	for _, origin := range []string{"https://a.com", "https://b.com"} {
+		req := httptest.NewRequest("GET", "/users/1", nil)
+		req.Header.Set("Origin", origin)
+		w := r.Perform(req)
+		if w.Body.String() != "user" || w.Header().Get("Access-Control-Allow-Origin") != origin ||
+			w.Header().Get("Access-Control-Allow-Credentials") != "true" || w.Header().Get("Access-Control-Expose-Headers") != "X-Total" {
+			t.Fatalf("%s: got %q %v", origin, w.Body.String(), w.Header())
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("Origin", "https://c.com")
+	if w := r.Perform(req); w.Header().Get("Access-Control-Allow-Origin") != "" || w.Body.String() != "user" {
+		t.Fatalf("disallowed origin got %v", w.Header())
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	r := newCORSTestEngine(CORSConfig{AllowOrigins: []string{"*"}})
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("Origin", "https://whatever.org")
+	if w := r.Perform(req); w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("Access-Control-Allow-Origin = %q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestCORSAnyOriginWithCredentials(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal(`"*" with AllowCredentials should panic`)
+		}
+	}()
+	CORS(CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+}
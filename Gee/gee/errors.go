@@ -0,0 +1,164 @@
+package gee
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrorType tells how much of an error may be shown to the client
+type ErrorType uint8
+
+const (
+	// ErrorTypePrivate errors are only logged, the client gets the status text
+	ErrorTypePrivate ErrorType = iota
+	// ErrorTypePublic errors are shown to the client
+	ErrorTypePublic
+	// ErrorTypeBind errors come from decoding the request, they are public and mean 400
+	ErrorTypeBind
+)
+
+// Error is an error reported through Context.Error
+type Error struct {
+	Err  error
+	Type ErrorType
+	Meta interface{}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// SetType changes the type of the error
+func (e *Error) SetType(t ErrorType) *Error {
+	e.Type = t
+	return e
+}
+
+// SetMeta attaches extra data, eg. the field which failed to bind
+func (e *Error) SetMeta(meta interface{}) *Error {
+	e.Meta = meta
+	return e
+}
+
+// HTTPError is an error with the response status and a machine readable code,
+// eg. NewHTTPError(404, "user_not_found", "user 42 does not exist")
+type HTTPError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func NewHTTPError(status int, code string, message string) *HTTPError {
+	return &HTTPError{Status: status, Code: code, Message: message}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Message == "" {
+		return http.StatusText(e.Status)
+	}
+	return e.Message
+}
+
+// Error records err for the ErrorHandler, a *HTTPError is public, other errors are private
+func (c *Context) Error(err error) *Error {
+	if err == nil {
+		panic("gee: Context.Error called with a nil error")
+	}
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Err: err, Type: ErrorTypePrivate}
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			e.Type = ErrorTypePublic
+		}
+	}
+	c.Errors = append(c.Errors, e)
+	return e
+}
+
+// AbortWithError records err and aborts, the response is left to the ErrorHandler
+func (c *Context) AbortWithError(err error) *Error {
+	c.Abort()
+	return c.Error(err)
+}
+
+// BindJSON decodes the request body into obj, a failure is recorded as a bind error
+func (c *Context) BindJSON(obj interface{}) error {
+	if c.Req.Body == nil {
+		err := errors.New("gee: missing request body")
+		c.Error(err).SetType(ErrorTypeBind)
+		return err
+	}
+	if err := c.engine.json.NewDecoder(c.Req.Body).Decode(obj); err != nil {
+		if bodyErr := c.bodyError(); bodyErr != nil {
+			if !c.bodyErrorSeen(bodyErr) {
+				c.Error(bodyErr)
+			}
+			return bodyErr
+		}
+		c.Error(err).SetType(ErrorTypeBind)
+		return err
+	}
+	return nil
+}
+
+// problem is a RFC 7807 problem details object
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code,omitempty"`
+}
+
+// ErrorHandler turns the errors collected during the chain into one
+// application/problem+json response, unless a response was written already.
+// The last error decides the status
+func ErrorHandler() HandlerFunc {
+	return func(c *Context) {
+		c.handlesErrors = true
+		c.Next()
+		if err := c.bodyError(); err != nil && !c.bodyErrorSeen(err) {
+			// a handler ignored the failed body read
+			c.Error(err)
+		}
+		if len(c.Errors) == 0 || c.writer.Written() {
+			return
+		}
+		for _, e := range c.Errors {
+			if e.Type == ErrorTypePrivate {
+				c.engine.logger.Printf("%s %s: %v", c.Method, c.Path, e.Err)
+			}
+		}
+		p := newProblem(c, c.Errors[len(c.Errors)-1])
+		c.SetHeader("Content-Type", "application/problem+json")
+		c.Status(p.Status)
+		_ = c.engine.json.NewEncoder(c.Writer).Encode(p)
+	}
+}
+
+func newProblem(c *Context, e *Error) *problem {
+	p := &problem{Type: "about:blank", Instance: c.Req.URL.Path}
+	var httpErr *HTTPError
+	switch {
+	case errors.As(e.Err, &httpErr):
+		p.Status = httpErr.Status
+		p.Code = httpErr.Code
+		p.Detail = httpErr.Error()
+	case e.Type == ErrorTypeBind:
+		p.Status = http.StatusBadRequest
+		p.Detail = e.Error()
+	default:
+		p.Status = http.StatusInternalServerError
+		if e.Type == ErrorTypePublic {
+			p.Detail = e.Error()
+		}
+	}
+	p.Title = http.StatusText(p.Status)
+	return p
+}
@@ -0,0 +1,100 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newErrorTestEngine() *Engine {
+	r := New()
+	r.Use(ErrorHandler())
+	r.GET("/http", func(c *Context) {
+		c.Error(NewHTTPError(http.StatusNotFound, "user_not_found", "user 42 does not exist"))
+	})
+	r.GET("/private", func(c *Context) {
+		c.Error(errors.New("db password is wrong"))
+	})
+	r.POST("/bind", func(c *Context) {
+		var v struct{ Name string }
+		if err := c.BindJSON(&v); err != nil {
+			return
+		}
+		c.String(http.StatusOK, v.Name)
+	})
+	r.GET("/written", func(c *Context) {
+		c.Error(errors.New("logged only"))
+		c.String(http.StatusOK, "ok")
+	})
+	return r
+}
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) problem {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	var p problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return p
+}
+
+func TestErrorHandlerHTTPError(t *testing.T) {
+	w := newErrorTestEngine().Perform(httptest.NewRequest("GET", "/http", nil))
+	p := decodeProblem(t, w)
+	if w.Code != 404 || p.Status != 404 || p.Code != "user_not_found" || p.Detail != "user 42 does not exist" || p.Instance != "/http" {
+		t.Fatalf("got %d %+v", w.Code, p)
+	}
+}
+
+func TestErrorHandlerPrivate(t *testing.T) {
+	w := newErrorTestEngine().Perform(httptest.NewRequest("GET", "/private", nil))
+	p := decodeProblem(t, w)
+	if w.Code != 500 || p.Title != "Internal Server Error" || strings.Contains(w.Body.String(), "password") {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestErrorHandlerBind(t *testing.T) {
+	r := newErrorTestEngine()
+	w := r.Perform(httptest.NewRequest("POST", "/bind", strings.NewReader("{bad json")))
+	if p := decodeProblem(t, w); w.Code != 400 || p.Detail == "" {
+		t.Fatalf("got %d %+v", w.Code, p)
+	}
+
+	w = r.Perform(httptest.NewRequest("POST", "/bind", strings.NewReader(`{"Name": "Tom"}`)))
+	if w.Code != 200 || w.Body.String() != "Tom" {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestErrorHandlerKeepsWrittenResponse(t *testing.T) {
+	w := newErrorTestEngine().Perform(httptest.NewRequest("GET", "/written", nil))
+	if w.Code != 200 || w.Body.String() != "ok" {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAbort(t *testing.T) {
+	r := New()
+	r.Use(ErrorHandler())
+	called := false
+	r.GET("/", func(c *Context) {
+		c.AbortWithError(NewHTTPError(http.StatusUnauthorized, "no_token", ""))
+	}, func(c *Context) {
+		called = true
+	})
+
+	w := r.Perform(httptest.NewRequest("GET", "/", nil))
+	if called || w.Code != 401 {
+		t.Fatalf("called %v, status %d", called, w.Code)
+	}
+	if p := decodeProblem(t, w); p.Detail != "Unauthorized" {
+		t.Fatalf("detail = %q", p.Detail)
+	}
+}
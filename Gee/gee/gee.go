@@ -2,20 +2,63 @@ package gee
 
 import (
 	"html/template"
+	"io/fs"
+	"net"
 	"net/http"
 	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type HandlerFunc func(*Context)
 
 // Engine abstract the Engine as the top RouterGroup
 type Engine struct {
-	*RouterGroup  					 // inner nested just like the inheritance, Engine can get all methods of RouterGroup
-	router        *router
-	groups        []*RouterGroup     // store all groups
-	htmlTemplates *template.Template // for html render
-	funcMap       template.FuncMap   // for html render
+	// RedirectTrailingSlash redirects /foo/ to /foo if the route is /foo, and the other way round
+	RedirectTrailingSlash bool
+	// RedirectFixedPath cleans .. and // from the path and matches it case-insensitively,
+	// the client is redirected to the route found
+	RedirectFixedPath bool
+	// RemoveExtraSlash serves /v1//hello as /v1/hello without a redirect
+	RemoveExtraSlash bool
+	// StrictPath leaves paths which are not canonical, eg. /v1/hello/ for the route /v1/hello, unmatched
+	StrictPath bool
+
+	*RouterGroup   // inner nested just like the inheritance, Engine can get all methods of RouterGroup
+	router         *router
+	groups         []*RouterGroup    // store all groups
+	html           *htmlRender       // for html render
+	funcMap        template.FuncMap  // for html render
+	layout         string            // base layout parsed with every page
+	partials       []string          // partial patterns parsed with the layout
+	mode           string            // DebugMode or ReleaseMode
+	namedRoutes    map[string]*Route // route name -> route, for reverse url generation
+	routes         []*Route          // all routes in registration order
+	trustedProxies []*net.IPNet      // proxies allowed to set forwarding headers
+	metrics        *metrics          // recorded by the Metrics middleware
+	noRoute        []HandlerFunc     // handlers for paths without any route
+	noMethod       []HandlerFunc     // handlers for paths without a route for the method
+	hosts          []*hostRoute      // host patterns with their own route trees
+	logger         LogPrinter        // for routes, requests, panics and template errors
+	logRoutes      *bool             // nil means only in DebugMode
+	json           JSONCodec         // for JSON rendering
+	maxBodySize    int64             // limit of every request body, 0 means no limit
+
+	mu    sync.RWMutex // guards groups, hosts, routes and namedRoutes, which may change while serving
+	state atomic.Value // *engineState, what ServeHTTP reads of groups and hosts
+}
+
+// engineState is a snapshot of the groups and hosts, published after every change
+type engineState struct {
+	groups []groupState
+	hosts  []*hostRoute
+}
+
+type groupState struct {
+	prefix      string
+	host        *hostRoute
+	middlewares []HandlerFunc
 }
 
 type RouterGroup struct {
@@ -23,24 +66,95 @@ type RouterGroup struct {
 	middlewares []HandlerFunc // support middleware
 	parent      *RouterGroup  // support nesting
 	engine      *Engine       // all groups share one Engine instance
+	host        *hostRoute    // nil for the default route tree
 }
 
-func New() *Engine {
-	engine := &Engine{router: newRouter()}
+// New creates an engine in DebugMode, opts change the defaults, eg.
+// gee.New(gee.WithMode(gee.ReleaseMode), gee.WithMaxBodySize(1<<20))
+func New(opts ...Option) *Engine {
+	engine := &Engine{
+		router:      newRouter(),
+		mode:        DebugMode,
+		namedRoutes: make(map[string]*Route),
+		metrics:     newMetrics(),
+		noRoute:     []HandlerFunc{notFound},
+		noMethod:    []HandlerFunc{methodNotAllowed},
+		json:        stdJSON{},
+	}
 	engine.RouterGroup = &RouterGroup{engine: engine}
 	engine.groups = []*RouterGroup{engine.RouterGroup}
+	for _, opt := range opts {
+		opt(engine)
+	}
+	engine.publish()
+	if engine.logger == nil {
+		engine.logger = defaultLogger
+		if engine.mode == TestMode {
+			engine.logger = discardLogger{}
+		}
+	}
 	return engine
 }
 
-func (engine *Engine) SetFuncMap(funcMap template.FuncMap){
+func (engine *Engine) SetFuncMap(funcMap template.FuncMap) {
 	engine.funcMap = funcMap
 }
 
-func( engine *Engine) LoadHTMLGlob(pattern string){
-	engine.htmlTemplates = template.Must(template.New("").Funcs(
-		engine.funcMap).ParseGlob(pattern))
+// SetHTMLLayout makes every page a copy of the layout, so pages only fill its blocks,
+// partials are glob patterns of shared templates parsed together with the layout.
+// It must be called before templates are loaded
+func (engine *Engine) SetHTMLLayout(layout string, partials ...string) {
+	engine.layout = layout
+	engine.partials = partials
+}
+
+func (engine *Engine) LoadHTMLGlob(pattern string) {
+	engine.loadHTML(nil, pattern)
+}
+
+// LoadHTMLFiles parses the given template files
+func (engine *Engine) LoadHTMLFiles(files ...string) {
+	engine.loadHTML(nil, files...)
 }
 
+// LoadHTMLFS parses the templates matching the patterns in fsys, eg. an embed.FS
+func (engine *Engine) LoadHTMLFS(fsys fs.FS, patterns ...string) {
+	engine.loadHTML(fsys, patterns...)
+}
+
+// loadHTML panics on a bad pattern or a pattern matching no files. A parse error
+// panics in release mode, in debug mode it is logged and shown in the browser
+// until the templates are fixed
+func (engine *Engine) loadHTML(fsys fs.FS, patterns ...string) {
+	// built-in functions, a function of the same name in funcMap wins
+	funcMap := template.FuncMap{"url": engine.URL}
+	for name, fn := range engine.funcMap {
+		funcMap[name] = fn
+	}
+	engine.html = newHTMLRender(fsys, patterns, engine.layout, engine.partials, funcMap)
+	if _, _, err := engine.html.resolve(); err != nil {
+		// a bad pattern does not get better by editing the templates
+		panic(err)
+	}
+	if err := engine.html.load(); err != nil {
+		if !engine.isDebug() {
+			panic(err)
+		}
+		engine.logger.Printf("load templates: %v", err)
+	}
+}
+
+// NoRoute replaces the handlers run when no route matches the path,
+// the middlewares of the matching groups still run in front of them
+func (engine *Engine) NoRoute(handlers ...HandlerFunc) {
+	engine.noRoute = handlers
+}
+
+// NoMethod replaces the handlers run when the path has routes, but not for
+// the request method. The Allow header is already set when they run
+func (engine *Engine) NoMethod(handlers ...HandlerFunc) {
+	engine.noMethod = handlers
+}
 
 // Group is defined to create a new RouterGroup
 // remember all groups share the same Engine instance
@@ -50,53 +164,145 @@ func (group *RouterGroup) Group(prefix string) *RouterGroup {
 		prefix: group.prefix + prefix,
 		parent: group,
 		engine: engine,
+		host:   group.host,
 	}
 
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
 	engine.groups = append(engine.groups, newGroup)
+	engine.publish()
 	return newGroup
 }
 
-func (group *RouterGroup) addRoute(method string, comp string, handler HandlerFunc) {
+// addRoute registers handlers for the pattern, all but the last one act as
+// middlewares of this route only
+func (group *RouterGroup) addRoute(method string, comp string, handlers []HandlerFunc) *Route {
+	if len(handlers) == 0 {
+		panic("gee: route " + method + " " + comp + " has no handler")
+	}
 	pattern := group.prefix + comp
-	group.engine.router.addRoute(method, pattern, handler)
+	engine := group.engine
+	if engine.shouldLogRoutes() {
+		engine.logger.Printf("Route %4s - %s", method, pattern)
+	}
+	group.router().addRoute(method, pattern, handlers)
+	route := &Route{Method: method, Pattern: pattern, handlers: handlers, engine: engine, host: group.host}
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	for i, old := range engine.routes {
+		// registering a route again replaces it, the name stays with the route
+		if old.Method == method && old.Pattern == pattern && old.host == group.host {
+			if old.name != "" && engine.namedRoutes[old.name] == old {
+				route.name = old.name
+				engine.namedRoutes[old.name] = route
+			}
+			engine.routes[i] = route
+			return route
+		}
+	}
+	engine.routes = append(engine.routes, route)
+	return route
+}
+
+// router returns the route tree the group registers into
+func (group *RouterGroup) router() *router {
+	if group.host != nil {
+		return group.host.router
+	}
+	return group.engine.router
 }
 
 // GET defines the method to add GET request
-func (group *RouterGroup) GET(pattern string, handler HandlerFunc) {
-	group.addRoute("GET", pattern, handler)
+func (group *RouterGroup) GET(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute("GET", pattern, handlers)
 }
 
 // POST defines the method to add POST request
-func (group *RouterGroup) POST(pattern string, handler HandlerFunc) {
-	group.addRoute("POST", pattern, handler)
+func (group *RouterGroup) POST(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute("POST", pattern, handlers)
+}
+
+// PUT defines the method to add PUT request
+func (group *RouterGroup) PUT(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute("PUT", pattern, handlers)
+}
+
+// PATCH defines the method to add PATCH request
+func (group *RouterGroup) PATCH(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute("PATCH", pattern, handlers)
+}
+
+// DELETE defines the method to add DELETE request
+func (group *RouterGroup) DELETE(pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute("DELETE", pattern, handlers)
+}
+
+// Handle adds a request of any method, eg. HEAD or a WebDAV method
+func (group *RouterGroup) Handle(method string, pattern string, handlers ...HandlerFunc) *Route {
+	return group.addRoute(strings.ToUpper(method), pattern, handlers)
 }
 
 // Use is defined to add middleware to the group
 func (group *RouterGroup) Use(middlewares ...HandlerFunc) {
+	engine := group.engine
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
 	group.middlewares = append(group.middlewares, middlewares...)
+	engine.publish()
+}
+
+// publish stores a new snapshot of the groups and hosts, engine.mu has to be held
+// except in New
+func (engine *Engine) publish() {
+	state := &engineState{
+		groups: make([]groupState, len(engine.groups)),
+		hosts:  append([]*hostRoute(nil), engine.hosts...),
+	}
+	for i, group := range engine.groups {
+		state.groups[i] = groupState{
+			prefix:      group.prefix,
+			host:        group.host,
+			middlewares: append([]HandlerFunc(nil), group.middlewares...),
+		}
+	}
+	engine.state.Store(state)
+}
+
+func (engine *Engine) loadState() *engineState {
+	return engine.state.Load().(*engineState)
 }
 
 func (engine *Engine) Run(addr string) (err error) {
-	return http.ListenAndServe(addr, engine)
+	server := &http.Server{Addr: addr, Handler: engine, ConnContext: ConnContext}
+	return server.ListenAndServe()
 }
 
 func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	var middlewares []HandlerFunc
+	c := newContext(w, req)
+	c.engine = engine
+	// guard the body before any handler, BodyLimit changes the limit later on
+	if engine.maxBodySize > 0 {
+		c.limitBody(engine.maxBodySize)
+	}
 
-	// judge a request should use what middleware
-	for _, group := range engine.groups {
-		if strings.HasPrefix(req.URL.Path, group.prefix) {
-			middlewares = append(middlewares, group.middlewares...)
-		}
+	// requests for an unknown host use the default route tree
+	r, host, hostParams := engine.routerFor(c)
+	if host != nil {
+		c.Params = hostParams
 	}
 
-	c := newContext(w, req)
-	c.handlers = middlewares
-	c.engine = engine
-	engine.router.handle(c)
+	// judge a request should use what middleware
+	c.handlers = engine.middlewaresFor(req.URL.Path, host)
+	r.handle(c)
+
+	// a body over the limit which no handler answered
+	if err := c.bodyError(); err != nil && !c.writer.Written() {
+		c.handlesErrors = false // the error handlers are done
+		c.answerBody(err)
+	}
 }
 
-//createStaticHandler is used to create static handler
+// createStaticHandler is used to create static handler
 func (group *RouterGroup) createStaticHandler(relativePath string, fs http.FileSystem) HandlerFunc {
 	absolutePath := path.Join(group.prefix, relativePath)
 	fileServer := http.StripPrefix(absolutePath, http.FileServer(fs))
@@ -112,7 +318,7 @@ func (group *RouterGroup) createStaticHandler(relativePath string, fs http.FileS
 	}
 }
 
-//Static is used to serve static files
+// Static is used to serve static files
 func (group *RouterGroup) Static(relativePath string, root string) {
 	handler := group.createStaticHandler(relativePath, http.Dir(root))
 	urlPattern := path.Join(relativePath, "/*filepath")
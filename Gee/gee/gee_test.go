@@ -0,0 +1,159 @@
+package gee
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGroupMiddleware(t *testing.T) {
+	var calls []string
+	mark := func(name string) HandlerFunc {
+		return func(c *Context) {
+			calls = append(calls, name)
+			c.Next()
+		}
+	}
+
+	r := New()
+	r.Use(mark("global"))
+	v1 := r.Group("/v1")
+	v1.Use(mark("v1"))
+	v1.GET("/hello", mark("route"), func(c *Context) {
+		calls = append(calls, "handler")
+		c.String(200, "hello")
+	})
+	r.GET("/other", func(c *Context) {
+		calls = append(calls, "other")
+	})
+
+	r.Perform(httptest.NewRequest("GET", "/v1/hello", nil))
+	if want := []string{"global", "v1", "route", "handler"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+
+	calls = nil
+	r.Perform(httptest.NewRequest("GET", "/other", nil))
+	if want := []string{"global", "other"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestMiddlewareAroundHandler(t *testing.T) {
+	var order []string
+	r := New()
+	r.Use(func(c *Context) {
+		order = append(order, "before")
+		c.Next()
+		order = append(order, "after")
+	})
+	r.GET("/", func(c *Context) {
+		order = append(order, "handler")
+	})
+
+	r.Perform(httptest.NewRequest("GET", "/", nil))
+	if want := []string{"before", "handler", "after"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	r := New()
+	r.Use(Recovery())
+	r.GET("/panic", func(c *Context) {
+		names := []string{"geektutu"}
+		c.String(200, names[100])
+	})
+
+	w := r.Perform(httptest.NewRequest("GET", "/panic", nil))
+	if w.Code != 500 {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+}
+
+func TestURL(t *testing.T) {
+	r := New()
+	v1 := r.Group("/v1")
+	v1.GET("/users/:id/files/*path", func(c *Context) {}).Name("file")
+
+	got, err := r.URL("file", "a b", "docs/c d.txt")
+	if err != nil || got != "/v1/users/a%20b/files/docs/c%20d.txt" {
+		t.Fatalf("URL = %q, %v", got, err)
+	}
+	if _, err := r.URL("file", 1); err == nil {
+		t.Fatal("missing wildcard value should fail")
+	}
+	if _, err := r.URL("nothing"); err == nil {
+		t.Fatal("unknown route name should fail")
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	r := New()
+	r.Use(Logger())
+	r.GET("/", func(c *Context) {})
+	r.Group("/admin").POST("/users", Recovery(), func(c *Context) {}).Name("createUser")
+
+	routes := r.Routes()
+	if len(routes) != 2 {
+		t.Fatalf("len(routes) = %d, want 2", len(routes))
+	}
+	got := routes[1]
+	if got.Method != "POST" || got.Path != "/admin/users" || got.Name != "createUser" || got.Middlewares != 2 {
+		t.Fatalf("unexpected route %+v", got)
+	}
+	if !strings.HasPrefix(got.Handler, "Gee/gee.TestRoutes") {
+		t.Fatalf("handler = %s", got.Handler)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	type user struct {
+		ID   int    `json:"id"`
+		Name string `json:"name,omitempty"`
+	}
+	r := New()
+	r.GET("/users/:id", func(c *Context) {}).Summary("get a user").Response(200, user{})
+
+	doc := r.OpenAPI(OpenAPIInfo{Title: "test", Version: "1"})
+	op := doc["paths"].(H)["/users/{id}"].(H)["get"].(H)
+	if op["summary"] != "get a user" {
+		t.Fatalf("summary = %v", op["summary"])
+	}
+	schemas := doc["components"].(H)["schemas"].(H)
+	if _, ok := schemas["user"]; !ok {
+		t.Fatalf("schema of user is missing: %v", schemas)
+	}
+	if id := schemas["user"].(H)["properties"].(H)["id"]; id.(H)["format"] != "int64" {
+		t.Fatalf("schema of an int = %v", id)
+	}
+}
+
+func TestOpenAPISameTypeName(t *testing.T) {
+	type user struct {
+		ID int `json:"id"`
+	}
+	r := New()
+	r.GET("/users", func(c *Context) {}).Response(200, user{})
+	{
+		type user struct {
+			Name string `json:"name"`
+		}
+		r.GET("/admins", func(c *Context) {}).Response(200, user{})
+	}
+	r.GET("/users/first", func(c *Context) {}).Response(200, user{})
+
+	doc := r.OpenAPI(OpenAPIInfo{Title: "test", Version: "1"})
+	schemas := doc["components"].(H)["schemas"].(H)
+	if len(schemas) != 2 || schemas["user"] == nil || schemas["user2"] == nil {
+		t.Fatalf("schemas = %v", schemas)
+	}
+	ref := func(path string) interface{} {
+		op := doc["paths"].(H)[path].(H)["get"].(H)
+		return op["responses"].(H)["200"].(H)["content"].(H)["application/json"].(H)["schema"].(H)["$ref"]
+	}
+	if ref("/users") != "#/components/schemas/user" || ref("/admins") != "#/components/schemas/user2" || ref("/users/first") != "#/components/schemas/user" {
+		t.Fatalf("refs = %v %v %v", ref("/users"), ref("/admins"), ref("/users/first"))
+	}
+}
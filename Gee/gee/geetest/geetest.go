@@ -0,0 +1,190 @@
+// Package geetest helps to test gee handlers without starting a server
+package geetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"Gee/gee"
+)
+
+// CreateTestContext returns a Context writing to w and the new engine it belongs to,
+// the request is a GET / and can be replaced through c.Req
+func CreateTestContext(w *httptest.ResponseRecorder) (*gee.Context, *gee.Engine) {
+	engine := gee.New(gee.WithMode(gee.TestMode))
+	c := engine.NewContext(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return c, engine
+}
+
+// RequestBuilder builds a request step by step, eg.
+// geetest.NewRequest("POST", "/users").JSON(user).Header("X-Token", "t").Build()
+type RequestBuilder struct {
+	method  string
+	path    string
+	query   url.Values
+	header  http.Header
+	cookies []*http.Cookie
+	body    io.Reader
+	err     error
+}
+
+// NewRequest starts a request with method and path, path may carry a query string
+func NewRequest(method, path string) *RequestBuilder {
+	return &RequestBuilder{
+		method: method,
+		path:   path,
+		query:  url.Values{},
+		header: http.Header{},
+	}
+}
+
+// Header sets a request header
+func (b *RequestBuilder) Header(key, value string) *RequestBuilder {
+	b.header.Set(key, value)
+	return b
+}
+
+// Cookie adds a cookie to the request
+func (b *RequestBuilder) Cookie(cookie *http.Cookie) *RequestBuilder {
+	b.cookies = append(b.cookies, cookie)
+	return b
+}
+
+// Query adds a query parameter
+func (b *RequestBuilder) Query(key, value string) *RequestBuilder {
+	b.query.Add(key, value)
+	return b
+}
+
+// Body sets a raw body
+func (b *RequestBuilder) Body(body []byte) *RequestBuilder {
+	b.body = bytes.NewReader(body)
+	return b
+}
+
+// JSON encodes v as the body and sets the Content-Type
+func (b *RequestBuilder) JSON(v interface{}) *RequestBuilder {
+	data, err := json.Marshal(v)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.header.Set("Content-Type", "application/json")
+	return b.Body(data)
+}
+
+// Form encodes values as a urlencoded body and sets the Content-Type
+func (b *RequestBuilder) Form(values url.Values) *RequestBuilder {
+	b.header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return b.Body([]byte(values.Encode()))
+}
+
+// Build returns the request, it panics if the JSON body could not be encoded
+func (b *RequestBuilder) Build() *http.Request {
+	if b.err != nil {
+		panic(fmt.Sprintf("geetest: build request: %v", b.err))
+	}
+	req := httptest.NewRequest(b.method, b.path, b.body)
+	if len(b.query) > 0 {
+		q := req.URL.Query()
+		for key, values := range b.query {
+			for _, value := range values {
+				q.Add(key, value)
+			}
+		}
+		req.URL.RawQuery = q.Encode()
+		req.RequestURI = req.URL.RequestURI()
+	}
+	for key, values := range b.header {
+		req.Header[key] = values
+	}
+	for _, cookie := range b.cookies {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+// AssertStatus checks the status code of the response
+func AssertStatus(t testing.TB, w *httptest.ResponseRecorder, code int) {
+	t.Helper()
+	if w.Code != code {
+		t.Errorf("status = %d, want %d, body: %s", w.Code, code, w.Body.String())
+	}
+}
+
+// AssertHeader checks a response header
+func AssertHeader(t testing.TB, w *httptest.ResponseRecorder, key, value string) {
+	t.Helper()
+	if got := w.Header().Get(key); got != value {
+		t.Errorf("header %s = %q, want %q", key, got, value)
+	}
+}
+
+// AssertBodyContains checks that the response body contains s
+func AssertBodyContains(t testing.TB, w *httptest.ResponseRecorder, s string) {
+	t.Helper()
+	if !strings.Contains(w.Body.String(), s) {
+		t.Errorf("body %q does not contain %q", w.Body.String(), s)
+	}
+}
+
+// AssertJSON checks a field of the JSON response, field is a dot separated
+// path like "user.tags.0", want is compared after a JSON round trip so
+// AssertJSON(t, w, "id", 1) matches {"id": 1}
+func AssertJSON(t testing.TB, w *httptest.ResponseRecorder, field string, want interface{}) {
+	t.Helper()
+	got, err := JSONField(w.Body.Bytes(), field)
+	if err != nil {
+		t.Errorf("%v, body: %s", err, w.Body.String())
+		return
+	}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Errorf("encode %v: %v", want, err)
+		return
+	}
+	var expect interface{}
+	_ = json.Unmarshal(data, &expect)
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("json field %s = %v, want %v", field, got, expect)
+	}
+}
+
+// JSONField decodes body and returns the value at the dot separated path
+func JSONField(body []byte, field string) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, fmt.Errorf("decode json: %v", err)
+	}
+	if field == "" {
+		return v, nil
+	}
+	for _, key := range strings.Split(field, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("json field %s: no key %q", field, key)
+			}
+			v = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("json field %s: bad index %q", field, key)
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf("json field %s: %q is not an object or array", field, key)
+		}
+	}
+	return v, nil
+}
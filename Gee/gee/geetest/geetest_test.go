@@ -0,0 +1,63 @@
+package geetest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"Gee/gee"
+)
+
+func TestCreateTestContext(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := CreateTestContext(w)
+	c.Params = map[string]string{"name": "geektutu"}
+
+	handler := func(c *gee.Context) {
+		c.JSON(http.StatusOK, gee.H{"name": c.Param("name")})
+	}
+	handler(c)
+
+	AssertStatus(t, w, http.StatusOK)
+	AssertJSON(t, w, "name", "geektutu")
+}
+
+func TestPerform(t *testing.T) {
+	r := gee.New()
+	r.POST("/users/:id", func(c *gee.Context) {
+		cookie, _ := c.Req.Cookie("session")
+		c.SetHeader("X-Session", cookie.Value)
+		c.JSON(http.StatusCreated, gee.H{
+			"id":    c.Param("id"),
+			"token": c.Req.Header.Get("X-Token"),
+			"page":  c.Query("page"),
+			"tags":  []int{1, 2},
+		})
+	})
+
+	req := NewRequest("POST", "/users/7").
+		JSON(gee.H{"name": "Tom"}).
+		Header("X-Token", "secret").
+		Cookie(&http.Cookie{Name: "session", Value: "s1"}).
+		Query("page", "2").
+		Build()
+	w := r.Perform(req)
+
+	AssertStatus(t, w, http.StatusCreated)
+	AssertHeader(t, w, "X-Session", "s1")
+	AssertHeader(t, w, "Content-Type", "application/json")
+	AssertJSON(t, w, "id", "7")
+	AssertJSON(t, w, "token", "secret")
+	AssertJSON(t, w, "page", "2")
+	AssertJSON(t, w, "tags.1", 2)
+}
+
+func TestJSONField(t *testing.T) {
+	body := []byte(`{"user": {"tags": ["a", "b"]}}`)
+	if v, err := JSONField(body, "user.tags.1"); err != nil || v != "b" {
+		t.Fatalf("JSONField = %v, %v", v, err)
+	}
+	if _, err := JSONField(body, "user.name"); err == nil {
+		t.Fatal("missing key should fail")
+	}
+}
@@ -0,0 +1,97 @@
+package gee
+
+import "strings"
+
+// hostRoute is a host pattern with its own route tree, eg. api.example.com or {tenant}.example.com
+type hostRoute struct {
+	pattern string
+	labels  []string
+	router  *router
+}
+
+func newHostRoute(pattern string) *hostRoute {
+	pattern = strings.ToLower(pattern)
+	return &hostRoute{
+		pattern: pattern,
+		labels:  strings.Split(pattern, "."),
+		router:  newRouter(),
+	}
+}
+
+func (h *hostRoute) isWild() bool {
+	return strings.Contains(h.pattern, "{")
+}
+
+// match compares host label by label, a {name} label matches any label and becomes a param
+func (h *hostRoute) match(labels []string) (map[string]string, bool) {
+	if len(labels) != len(h.labels) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, label := range h.labels {
+		if len(label) > 2 && label[0] == '{' && label[len(label)-1] == '}' {
+			if labels[i] == "" {
+				return nil, false
+			}
+			params[label[1:len(label)-1]] = labels[i]
+		} else if label != labels[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// Host returns a group whose routes only match requests for the host pattern,
+// eg. api.example.com or {tenant}.example.com where c.Param("tenant") gives the label.
+// Each host has its own route tree, requests for other hosts use the default tree
+func (engine *Engine) Host(pattern string) *RouterGroup {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	var host *hostRoute
+	for _, h := range engine.hosts {
+		if h.pattern == strings.ToLower(pattern) {
+			host = h
+		}
+	}
+	if host == nil {
+		host = newHostRoute(pattern)
+		engine.hosts = append(engine.hosts, host)
+	}
+	group := &RouterGroup{
+		parent: engine.RouterGroup,
+		engine: engine,
+		host:   host,
+	}
+	engine.groups = append(engine.groups, group)
+	engine.publish()
+	return group
+}
+
+// routerFor returns the route tree serving the request, the matched host and its params
+func (engine *Engine) routerFor(c *Context) (*router, *hostRoute, map[string]string) {
+	host, params := engine.matchHost(c.Host())
+	if host != nil {
+		return host.router, host, params
+	}
+	return engine.router, nil, nil
+}
+
+// matchHost finds the host route of the request, exact patterns win over patterns with params
+func (engine *Engine) matchHost(host string) (*hostRoute, map[string]string) {
+	hosts := engine.loadState().hosts
+	if len(hosts) == 0 {
+		return nil, nil
+	}
+	labels := strings.Split(strings.TrimSuffix(hostname(host), "."), ".")
+	for _, wild := range []bool{false, true} {
+		for _, h := range hosts {
+			if h.isWild() != wild {
+				continue
+			}
+			if params, ok := h.match(labels); ok {
+				return h, params
+			}
+		}
+	}
+	return nil, nil
+}
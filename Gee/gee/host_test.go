@@ -0,0 +1,64 @@
+package gee
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func newHostTestEngine() *Engine {
+	r := New()
+	r.GET("/", func(c *Context) {
+		c.String(200, "default")
+	})
+	api := r.Host("api.example.com")
+	api.GET("/", func(c *Context) {
+		c.String(200, "api")
+	})
+	tenant := r.Host("{tenant}.example.com")
+	tenant.Use(func(c *Context) {
+		c.SetHeader("X-Tenant", c.Param("tenant"))
+		c.Next()
+	})
+	tenant.Group("/users").GET("/:id", func(c *Context) {
+		c.String(200, "%s/%s", c.Param("tenant"), c.Param("id"))
+	})
+	return r
+}
+
+func performHost(r *Engine, host, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	req.Host = host
+	return r.Perform(req)
+}
+
+func TestHostRouting(t *testing.T) {
+	r := newHostTestEngine()
+	tests := []struct {
+		host, path string
+		code       int
+		body       string
+	}{
+		{"api.example.com", "/", 200, "api"},
+		{"API.example.com:8080", "/", 200, "api"},
+		{"acme.example.com", "/users/7", 200, "acme/7"},
+		{"other.org", "/", 200, "default"},
+		{"acme.example.com", "/", 404, "404 NOT FOUND: /\n"},
+		{"a.b.example.com", "/", 200, "default"},
+	}
+	for _, tt := range tests {
+		w := performHost(r, tt.host, tt.path)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("%s%s: got %d %q, want %d %q", tt.host, tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+}
+
+func TestHostMiddlewareIsolated(t *testing.T) {
+	r := newHostTestEngine()
+	if w := performHost(r, "acme.example.com", "/users/1"); w.Header().Get("X-Tenant") != "acme" {
+		t.Fatalf("X-Tenant = %q", w.Header().Get("X-Tenant"))
+	}
+	if w := performHost(r, "api.example.com", "/"); w.Header().Get("X-Tenant") != "" {
+		t.Fatal("tenant middleware should not run for api.example.com")
+	}
+}
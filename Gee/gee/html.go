@@ -0,0 +1,195 @@
+package gee
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// htmlRender owns the parsed templates together with the information
+// needed to parse them again, so debug mode can reload them on change
+type htmlRender struct {
+	mu sync.RWMutex
+
+	fsys     fs.FS    // nil means the os filesystem
+	patterns []string // page patterns (or plain file names)
+	layout   string   // base layout file, empty if no layout is used
+	partials []string // partial patterns parsed together with the layout
+	funcMap  template.FuncMap
+
+	modTimes map[string]time.Time // file -> modification time at last parse
+	root     *template.Template   // every page in one set when no layout is used
+	pages    map[string]*template.Template
+	err      error // the last parse error, reported on every render
+}
+
+func newHTMLRender(fsys fs.FS, patterns []string, layout string, partials []string, funcMap template.FuncMap) *htmlRender {
+	return &htmlRender{
+		fsys:     fsys,
+		patterns: patterns,
+		layout:   layout,
+		partials: partials,
+		funcMap:  funcMap,
+	}
+}
+
+func (r *htmlRender) glob(pattern string) ([]string, error) {
+	if r.fsys != nil {
+		return fs.Glob(r.fsys, pattern)
+	}
+	return filepath.Glob(pattern)
+}
+
+func (r *htmlRender) modTime(name string) (time.Time, error) {
+	var info fs.FileInfo
+	var err error
+	if r.fsys != nil {
+		info, err = fs.Stat(r.fsys, name)
+	} else {
+		info, err = os.Stat(name)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+func (r *htmlRender) parseFiles(t *template.Template, files ...string) (*template.Template, error) {
+	if r.fsys != nil {
+		return t.ParseFS(r.fsys, files...)
+	}
+	return t.ParseFiles(files...)
+}
+
+// resolve expands all patterns, the layout files are excluded from the pages
+func (r *htmlRender) resolve() (pages []string, layouts []string, err error) {
+	seen := make(map[string]bool)
+	if r.layout != "" {
+		layouts = append(layouts, r.layout)
+		seen[r.layout] = true
+		for _, pattern := range r.partials {
+			matches, err := r.glob(pattern)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, file := range matches {
+				if !seen[file] {
+					seen[file] = true
+					layouts = append(layouts, file)
+				}
+			}
+		}
+	}
+	for _, pattern := range r.patterns {
+		matches, err := r.glob(pattern)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(matches) == 0 {
+			return nil, nil, fmt.Errorf("html/template: pattern matches no files: %#q", pattern)
+		}
+		for _, file := range matches {
+			if !seen[file] {
+				seen[file] = true
+				pages = append(pages, file)
+			}
+		}
+	}
+	sort.Strings(pages)
+	return pages, layouts, nil
+}
+
+// load parses every template again and keeps the error for later renders
+func (r *htmlRender) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.root, r.pages, r.err = nil, nil, nil
+	pages, layouts, err := r.resolve()
+	if err != nil {
+		r.err = err
+		return err
+	}
+
+	r.modTimes = make(map[string]time.Time)
+	for _, file := range append(layouts, pages...) {
+		if t, err := r.modTime(file); err == nil {
+			r.modTimes[file] = t
+		}
+	}
+
+	if r.layout == "" {
+		r.root, r.err = r.parseFiles(template.New("").Funcs(r.funcMap), pages...)
+		return r.err
+	}
+
+	// every page gets its own copy of the layout, so pages can
+	// fill the same blocks without overriding each other
+	base, err := r.parseFiles(template.New("").Funcs(r.funcMap), layouts...)
+	if err != nil {
+		r.err = err
+		return err
+	}
+	r.pages = make(map[string]*template.Template, len(pages))
+	for _, file := range pages {
+		t, err := base.Clone()
+		if err == nil {
+			t, err = r.parseFiles(t, file)
+		}
+		if err != nil {
+			r.pages = nil
+			r.err = err
+			return err
+		}
+		r.pages[path.Base(filepath.ToSlash(file))] = t
+	}
+	r.root = base
+	return nil
+}
+
+// changed reports whether a template file was added, removed or modified since the last parse
+func (r *htmlRender) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	pages, layouts, err := r.resolve()
+	if err != nil {
+		return r.err == nil
+	}
+	files := append(layouts, pages...)
+	if len(files) != len(r.modTimes) {
+		return true
+	}
+	for _, file := range files {
+		t, err := r.modTime(file)
+		if old, ok := r.modTimes[file]; err != nil || !ok || !t.Equal(old) {
+			return true
+		}
+	}
+	return false
+}
+
+// execute renders the page name, with reload set the templates are parsed again if they changed
+func (r *htmlRender) execute(w io.Writer, name string, data interface{}, reload bool) error {
+	if reload && r.changed() {
+		_ = r.load()
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.err != nil {
+		return r.err
+	}
+	if t, ok := r.pages[name]; ok {
+		return t.ExecuteTemplate(w, path.Base(filepath.ToSlash(r.layout)), data)
+	}
+	return r.root.ExecuteTemplate(w, name, data)
+}
@@ -0,0 +1,123 @@
+package gee
+
+import (
+	"errors"
+	"html/template"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTemplates writes files into dir
+func writeTemplates(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func renderPage(r *Engine, name string, data interface{}) *httptest.ResponseRecorder {
+	r.GET("/"+name, func(c *Context) {
+		c.HTML(200, name, data)
+	})
+	return r.Perform(httptest.NewRequest("GET", "/"+name, nil))
+}
+
+func TestHTMLLayout(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplates(t, dir, map[string]string{
+		"layout.tmpl": `<main>{{template "content" .}}</main>{{template "footer"}}`,
+		"footer.tmpl": `{{define "footer"}}<footer>f</footer>{{end}}`,
+		"a.tmpl":      `{{define "content"}}A {{.}}{{end}}`,
+		"b.tmpl":      `{{define "content"}}B {{.}}{{end}}`,
+	})
+	r := New(WithMode(ReleaseMode))
+	r.SetHTMLLayout(filepath.Join(dir, "layout.tmpl"), filepath.Join(dir, "footer.tmpl"))
+	r.LoadHTMLGlob(filepath.Join(dir, "*.tmpl"))
+
+	for name, want := range map[string]string{
+		"a.tmpl": "<main>A &lt;x&gt;</main><footer>f</footer>",
+		"b.tmpl": "<main>B &lt;x&gt;</main><footer>f</footer>",
+	} {
+		if w := renderPage(r, name, "<x>"); w.Code != 200 || w.Body.String() != want {
+			t.Errorf("%s: %d %q, want %q", name, w.Code, w.Body.String(), want)
+		}
+	}
+}
+
+func TestLoadHTMLFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplates(t, dir, map[string]string{
+		"hello.tmpl": `hello {{.}}`,
+		"other.tmpl": `other`,
+	})
+	r := New(WithMode(ReleaseMode))
+	r.LoadHTMLFiles(filepath.Join(dir, "hello.tmpl"))
+	if w := renderPage(r, "hello.tmpl", "gee"); w.Body.String() != "hello gee" {
+		t.Fatalf("body = %q", w.Body.String())
+	}
+	if w := renderPage(r, "other.tmpl", nil); w.Code != 500 {
+		t.Fatalf("a file which was not loaded: status = %d", w.Code)
+	}
+}
+
+func TestHTMLReload(t *testing.T) {
+	for mode, reloads := range map[string]bool{DebugMode: true, ReleaseMode: false} {
+		dir := t.TempDir()
+		writeTemplates(t, dir, map[string]string{"page.tmpl": `v1`})
+		r := New(WithMode(mode))
+		r.LoadHTMLGlob(filepath.Join(dir, "*.tmpl"))
+		r.GET("/", func(c *Context) {
+			c.HTML(200, "page.tmpl", nil)
+		})
+		if w := r.Perform(httptest.NewRequest("GET", "/", nil)); w.Body.String() != "v1" {
+			t.Fatalf("%s: body = %q", mode, w.Body.String())
+		}
+
+		writeTemplates(t, dir, map[string]string{"page.tmpl": `v2`})
+		// a later modification time, the change may fall within the mtime resolution
+		later := time.Now().Add(time.Hour)
+		if err := os.Chtimes(filepath.Join(dir, "page.tmpl"), later, later); err != nil {
+			t.Fatal(err)
+		}
+		want := "v1"
+		if reloads {
+			want = "v2"
+		}
+		if w := r.Perform(httptest.NewRequest("GET", "/", nil)); w.Body.String() != want {
+			t.Errorf("%s: body after the change = %q, want %q", mode, w.Body.String(), want)
+		}
+	}
+}
+
+func TestHTMLErrorPage(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplates(t, dir, map[string]string{"page.tmpl": `{{fail}}`})
+	r := New()
+	r.SetFuncMap(template.FuncMap{"fail": func() (string, error) {
+		return "", errors.New("<script>alert(1)</script>")
+	}})
+	r.LoadHTMLGlob(filepath.Join(dir, "*.tmpl"))
+
+	w := renderPage(r, "page.tmpl", nil)
+	if w.Code != 500 || !strings.Contains(w.Body.String(), "&lt;script&gt;") || strings.Contains(w.Body.String(), "<script>") {
+		t.Fatalf("%d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestLoadHTMLGlobBadPattern(t *testing.T) {
+	for _, mode := range []string{DebugMode, ReleaseMode} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: a bad pattern should panic", mode)
+				}
+			}()
+			New(WithMode(mode)).LoadHTMLGlob("[")
+		}()
+	}
+}
@@ -0,0 +1,231 @@
+package gee
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HeaderIdempotencyKey is the header naming a request, a retry sends the same key
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// IdempotencyRecord is the state of an Idempotency-Key, the response is set once it is done
+type IdempotencyRecord struct {
+	Fingerprint string
+	Done        bool
+	Status      int
+	Header      http.Header
+	Body        []byte
+}
+
+// IdempotencyStore keeps the records of Idempotency, it has to be safe for concurrent use
+type IdempotencyStore interface {
+	// Begin reserves key for a request with fingerprint. If the key is taken
+	// it returns the record of the key and false
+	Begin(key string, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool)
+	// Complete stores the response of the request which reserved key
+	Complete(key string, record *IdempotencyRecord, ttl time.Duration)
+	// Release drops a reservation, so the request can be sent again
+	Release(key string)
+}
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore, records expire after their ttl
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*idempotencyEntry
+	swept   time.Time
+}
+
+type idempotencyEntry struct {
+	record  *IdempotencyRecord
+	expires time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]*idempotencyEntry)}
+}
+
+func (s *MemoryIdempotencyStore) Begin(key string, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.swept) > time.Minute {
+		s.sweep(now)
+	}
+	if entry, ok := s.records[key]; ok && now.Before(entry.expires) {
+		return entry.record, false
+	}
+	s.records[key] = &idempotencyEntry{record: &IdempotencyRecord{Fingerprint: fingerprint}, expires: now.Add(ttl)}
+	return nil, true
+}
+
+func (s *MemoryIdempotencyStore) Complete(key string, record *IdempotencyRecord, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = &idempotencyEntry{record: record, expires: time.Now().Add(ttl)}
+}
+
+func (s *MemoryIdempotencyStore) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+}
+
+// sweep drops the expired records
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	s.swept = now
+	for key, entry := range s.records {
+		if !now.Before(entry.expires) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// IdempotencyConfig configures IdempotencyWithConfig
+type IdempotencyConfig struct {
+	// Store keeps the records, nil keeps them in memory
+	Store IdempotencyStore
+	// TTL is how long a key is kept, default 24 hours
+	TTL time.Duration
+	// Scope names the client owning a key, keys of different clients never meet.
+	// nil uses a hash of the Authorization header, or the client IP without one
+	Scope func(*Context) string
+	// MaxBody is the longest body fingerprinted, default 1 MB, longer ones get 413
+	MaxBody int64
+}
+
+// Idempotency replays the response of POST, PUT, PATCH and DELETE requests
+// sent again with the same Idempotency-Key, keys are kept in memory for 24 hours.
+// See IdempotencyWithConfig
+func Idempotency() HandlerFunc {
+	return IdempotencyWithConfig(IdempotencyConfig{})
+}
+
+// IdempotencyWithStore is Idempotency keeping the keys in store for ttl
+func IdempotencyWithStore(store IdempotencyStore, ttl time.Duration) HandlerFunc {
+	return IdempotencyWithConfig(IdempotencyConfig{Store: store, TTL: ttl})
+}
+
+// IdempotencyWithConfig ties the Idempotency-Key of unsafe requests to a fingerprint
+// of the method, the URI and the body, keys are scoped to the client by Scope.
+// The first response is kept and replayed with Idempotent-Replayed: true for repeats,
+// only the headers set behind the middleware are kept. A request reusing a key
+// with another fingerprint gets 422, a repeat while the first request is still
+// running gets 409, a body over MaxBody gets 413. Responses with a 5xx status
+// are not kept, so the client can retry
+func IdempotencyWithConfig(config IdempotencyConfig) HandlerFunc {
+	store, ttl, scope, maxBody := config.Store, config.TTL, config.Scope, config.MaxBody
+	if store == nil {
+		store = NewMemoryIdempotencyStore()
+	}
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	if scope == nil {
+		scope = idempotencyScope
+	}
+	if maxBody <= 0 {
+		maxBody = 1 << 20
+	}
+
+	return func(c *Context) {
+		key := c.Req.Header.Get(HeaderIdempotencyKey)
+		if key == "" || !isUnsafe(c.Method) {
+			c.Next()
+			return
+		}
+		key = scope(c) + " " + key
+
+		fingerprint, err := c.fingerprint(maxBody)
+		if err != nil {
+			if bodyErr := c.bodyError(); bodyErr != nil {
+				c.answerBody(bodyErr)
+			} else {
+				c.String(http.StatusBadRequest, "400 BAD REQUEST: %v\n", err)
+			}
+			c.Abort()
+			return
+		}
+
+		record, ok := store.Begin(key, fingerprint, ttl)
+		if !ok {
+			switch {
+			case record.Fingerprint != fingerprint:
+				c.String(http.StatusUnprocessableEntity, "422 UNPROCESSABLE ENTITY: %s was used for another request\n", HeaderIdempotencyKey)
+			case !record.Done:
+				c.String(http.StatusConflict, "409 CONFLICT: a request with this %s is in progress\n", HeaderIdempotencyKey)
+			default:
+				header := c.Writer.Header()
+				for name, values := range record.Header {
+					header[name] = append([]string(nil), values...)
+				}
+				header.Set("Idempotent-Replayed", "true")
+				c.Status(record.Status)
+				c.Writer.Write(record.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		completed := false
+		defer func() {
+			// a panic or an unkept response frees the key
+			if !completed {
+				store.Release(key)
+			}
+		}()
+		writer := c.Writer
+		capture := newCaptureWriter(writer)
+		c.Writer = capture
+		c.Next()
+		c.Writer = writer
+
+		if capture.status == 0 || capture.status >= http.StatusInternalServerError {
+			return
+		}
+		store.Complete(key, &IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Done:        true,
+			Status:      capture.status,
+			Header:      capture.header,
+			Body:        capture.body.Bytes(),
+		}, ttl)
+		completed = true
+	}
+}
+
+// idempotencyScope hashes the Authorization header, so no credentials end up in
+// the store, requests without one are told apart by the client IP
+func idempotencyScope(c *Context) string {
+	if auth := c.Req.Header.Get("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		return "auth:" + hex.EncodeToString(sum[:])
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// fingerprint hashes the method, the URI and the body of at most maxBody bytes,
+// the body is put back for the handlers
+func (c *Context) fingerprint(maxBody int64) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, c.Method+" "+c.Req.URL.RequestURI()+"\n")
+	if c.Req.Body != nil && c.Req.Body != http.NoBody {
+		body, err := c.bufferBody(maxBody)
+		if err != nil {
+			return "", err
+		}
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func isUnsafe(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
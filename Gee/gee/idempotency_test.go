@@ -0,0 +1,148 @@
+package gee
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIdempotency(t *testing.T) {
+	r := New(WithMode(TestMode))
+	var orders int64
+	r.POST("/orders", Idempotency(), func(c *Context) {
+		body, _ := io.ReadAll(c.Req.Body)
+		n := atomic.AddInt64(&orders, 1)
+		c.SetHeader("Location", "/orders/1")
+		c.String(201, "order %d: %s", n, body)
+	})
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(HeaderIdempotencyKey, key)
+		}
+		return r.Perform(req)
+	}
+
+	first := post("k1", "book")
+	again := post("k1", "book")
+	if first.Code != 201 || again.Code != 201 || again.Body.String() != "order 1: book" || orders != 1 {
+		t.Fatalf("replay: %d %q, orders = %d", again.Code, again.Body.String(), orders)
+	}
+	if again.Header().Get("Idempotent-Replayed") != "true" || again.Header().Get("Location") != "/orders/1" {
+		t.Fatalf("replayed headers = %v", again.Header())
+	}
+	if w := post("k1", "pen"); w.Code != 422 {
+		t.Fatalf("reused key status = %d", w.Code)
+	}
+	if w := post("", "book"); w.Body.String() != "order 2: book" {
+		t.Fatalf("without a key body = %q", w.Body.String())
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	r := New(WithMode(TestMode))
+	started, release := make(chan struct{}), make(chan struct{})
+	r.POST("/pay", Idempotency(), func(c *Context) {
+		close(started)
+		<-release
+		c.String(200, "paid")
+	})
+	pay := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/pay", strings.NewReader("10"))
+		req.Header.Set(HeaderIdempotencyKey, "p1")
+		return r.Perform(req)
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- pay() }()
+	<-started
+	if w := pay(); w.Code != 409 {
+		t.Fatalf("concurrent duplicate status = %d", w.Code)
+	}
+	close(release)
+	if w := <-done; w.Body.String() != "paid" {
+		t.Fatalf("body = %q", w.Body.String())
+	}
+}
+
+func TestIdempotencyServerErrorNotKept(t *testing.T) {
+	r := New(WithMode(TestMode))
+	var calls int64
+	r.POST("/flaky", Idempotency(), func(c *Context) {
+		if atomic.AddInt64(&calls, 1) == 1 {
+			c.String(500, "try again")
+			return
+		}
+		c.String(200, "ok")
+	})
+	for _, want := range []int{500, 200, 200} {
+		req := httptest.NewRequest("POST", "/flaky", nil)
+		req.Header.Set(HeaderIdempotencyKey, "f1")
+		if w := r.Perform(req); w.Code != want {
+			t.Fatalf("status = %d, want %d", w.Code, want)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("calls = %d", calls)
+	}
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	r := New(WithMode(TestMode))
+	r.Use(func(c *Context) {
+		c.SetHeader("X-Request-ID", c.Req.Header.Get("X-Test-ID"))
+		c.Next()
+	})
+	var orders int64
+	r.POST("/orders", Idempotency(), func(c *Context) {
+		c.String(201, "order %d", atomic.AddInt64(&orders, 1))
+	})
+	post := func(auth, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader("book"))
+		req.Header.Set(HeaderIdempotencyKey, "k1")
+		req.Header.Set("Authorization", auth)
+		req.Header.Set("X-Test-ID", id)
+		return r.Perform(req)
+	}
+
+	post("Bearer alice", "1")
+	if w := post("Bearer bob", "2"); w.Body.String() != "order 2" {
+		t.Fatalf("another client got the response of the key: %q", w.Body.String())
+	}
+	w := post("Bearer alice", "3")
+	if w.Body.String() != "order 1" || w.Header().Get("X-Request-ID") != "3" {
+		t.Fatalf("replay: %q, X-Request-ID = %v", w.Body.String(), w.Header().Values("X-Request-ID"))
+	}
+}
+
+func TestIdempotencyBody(t *testing.T) {
+	// the engine limit guards the body before the middleware reads it
+	r := New(WithMode(TestMode), WithMaxBodySize(1<<20))
+	r.Use(IdempotencyWithConfig(IdempotencyConfig{MaxBody: 16}))
+	read := func(c *Context) {
+		if body, err := io.ReadAll(c.Req.Body); err == nil {
+			c.String(200, "%s", body)
+		}
+	}
+	r.POST("/", read)
+	r.POST("/small", BodyLimit(4), read)
+	post := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.ContentLength = -1
+		req.Header.Set(HeaderIdempotencyKey, key)
+		return r.Perform(req)
+	}
+
+	if w := post("/", "k1", "fits"); w.Code != 200 || w.Body.String() != "fits" {
+		t.Fatalf("%d %q", w.Code, w.Body.String())
+	}
+	if w := post("/", "k2", "longer than sixteen bytes"); w.Code != 413 {
+		t.Fatalf("body over MaxBody: status = %d", w.Code)
+	}
+	// the buffered body is still read through the limit of the route
+	if w := post("/small", "k3", "ten bytes!"); w.Code != 413 {
+		t.Fatalf("body over the route limit: status = %d", w.Code)
+	}
+}
@@ -0,0 +1,49 @@
+package gee
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONCodec encodes and decodes JSON for an engine, Context.JSON, BindJSON
+// and the ErrorHandler all go through it. encoding/json is the default, a
+// faster implementation is registered with WithJSONCodec or SetJSONCodec
+type JSONCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	NewEncoder(w io.Writer) JSONEncoder
+	NewDecoder(r io.Reader) JSONDecoder
+}
+
+// JSONEncoder writes JSON values to a stream, *json.Encoder satisfies it
+type JSONEncoder interface {
+	Encode(v interface{}) error
+}
+
+// JSONDecoder reads JSON values from a stream, *json.Decoder satisfies it
+type JSONDecoder interface {
+	Decode(v interface{}) error
+}
+
+type stdJSON struct{}
+
+func (stdJSON) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (stdJSON) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (stdJSON) NewEncoder(w io.Writer) JSONEncoder {
+	return json.NewEncoder(w)
+}
+
+func (stdJSON) NewDecoder(r io.Reader) JSONDecoder {
+	return json.NewDecoder(r)
+}
+
+// SetJSONCodec replaces the JSON codec of the engine
+func (engine *Engine) SetJSONCodec(codec JSONCodec) {
+	engine.json = codec
+}
@@ -0,0 +1,64 @@
+package gee
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// countingCodec is encoding/json counting how often each entry point is used
+type countingCodec struct {
+	marshal, decode int
+}
+
+func (c *countingCodec) Marshal(v interface{}) ([]byte, error) {
+	c.marshal++
+	return json.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (c *countingCodec) NewEncoder(w io.Writer) JSONEncoder {
+	return json.NewEncoder(w)
+}
+
+func (c *countingCodec) NewDecoder(r io.Reader) JSONDecoder {
+	c.decode++
+	return json.NewDecoder(r)
+}
+
+func TestJSONCodecRenderAndBind(t *testing.T) {
+	codec := &countingCodec{}
+	r := New()
+	r.SetJSONCodec(codec)
+	r.POST("/echo", func(c *Context) {
+		var body H
+		if err := c.BindJSON(&body); err != nil {
+			c.String(400, err.Error())
+			return
+		}
+		c.JSON(200, body)
+	})
+
+	w := r.Perform(httptest.NewRequest("POST", "/echo", strings.NewReader(`{"name":"Tom"}`)))
+	if w.Body.String() != "{\"name\":\"Tom\"}\n" {
+		t.Fatalf("body = %q", w.Body.String())
+	}
+	if codec.marshal != 1 || codec.decode != 1 {
+		t.Fatalf("codec used %d marshal, %d decode, want 1 and 1", codec.marshal, codec.decode)
+	}
+}
+
+func TestJSONMarshalError(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) {
+		c.JSON(200, H{"ch": make(chan int)})
+	})
+	if w := r.Perform(httptest.NewRequest("GET", "/", nil)); w.Code != 500 {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+}
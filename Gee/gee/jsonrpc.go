@@ -0,0 +1,364 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// the error codes of the JSON-RPC 2.0 specification
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+	// RPCServerError is answered when a middleware aborts the call
+	RPCServerError = -32000
+)
+
+// RPCError is a JSON-RPC error, a method returning one sends it to the client as it is,
+// any other error is logged and answered as an internal error
+type RPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("jsonrpc: %d %s", e.Code, e.Message)
+}
+
+// RPCRegistry holds the methods served by JSONRPC
+type RPCRegistry struct {
+	mu          sync.RWMutex
+	methods     map[string]*rpcMethod
+	middlewares []HandlerFunc
+}
+
+type rpcMethod struct {
+	fn          reflect.Value
+	params      reflect.Type // nil if the method takes no params
+	middlewares []HandlerFunc
+}
+
+var (
+	contextType = reflect.TypeOf((*Context)(nil))
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+func NewRPCRegistry() *RPCRegistry {
+	return &RPCRegistry{methods: make(map[string]*rpcMethod)}
+}
+
+// Use adds middlewares run in front of every method
+func (registry *RPCRegistry) Use(middlewares ...HandlerFunc) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.middlewares = append(registry.middlewares, middlewares...)
+}
+
+// Register adds the method name, fn is a func(*gee.Context, T) (R, error) or a
+// func(*gee.Context) (R, error). The params are decoded into T, by name from an
+// object or by position from an array into the fields of a struct, the result R
+// is encoded as JSON. middlewares run in front of this method only
+func (registry *RPCRegistry) Register(name string, fn interface{}, middlewares ...HandlerFunc) {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() < 1 || t.NumIn() > 2 || t.In(0) != contextType ||
+		t.NumOut() != 2 || t.Out(1) != errorType {
+		panic("gee: RPC method " + name + " must be a func(*gee.Context, T) (R, error)")
+	}
+	method := &rpcMethod{fn: v, middlewares: middlewares}
+	if t.NumIn() == 2 {
+		method.params = t.In(1)
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, ok := registry.methods[name]; ok {
+		panic("gee: RPC method " + name + " is already registered")
+	}
+	registry.methods[name] = method
+}
+
+func (registry *RPCRegistry) lookup(name string) (*rpcMethod, []HandlerFunc) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return registry.methods[name], registry.middlewares
+}
+
+// rpcRequest keeps ID raw, nil when the member is missing, which makes the request a notification
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+var rpcNullID = json.RawMessage("null")
+
+// JSONRPC serves the methods of registry as a JSON-RPC 2.0 endpoint, eg.
+// r.POST("/rpc", gee.JSONRPC(registry)). Batches are answered with an array,
+// notifications without a response, a request made only of notifications gets 204.
+// Every call runs the registry and method middlewares with its own Context,
+// it shares Keys with the HTTP request and its FullPath is the route pattern
+// followed by # and the method name, eg. /rpc#users.get
+func JSONRPC(registry *RPCRegistry) HandlerFunc {
+	return func(c *Context) {
+		body, err := io.ReadAll(c.Req.Body)
+		if err != nil {
+			if bodyErr := c.bodyError(); bodyErr != nil {
+				c.answerBody(bodyErr)
+				return
+			}
+			c.String(http.StatusBadRequest, "400 BAD REQUEST: %v\n", err)
+			return
+		}
+		codec := c.engine.json
+		body = bytes.TrimSpace(body)
+
+		if len(body) > 0 && body[0] == '[' {
+			var batch []json.RawMessage
+			if err := codec.Unmarshal(body, &batch); err != nil {
+				c.rpcReply(rpcFailure(rpcNullID, RPCParseError, "Parse error"))
+				return
+			}
+			if len(batch) == 0 {
+				c.rpcReply(rpcFailure(rpcNullID, RPCInvalidRequest, "Invalid Request"))
+				return
+			}
+			responses := make([]*rpcResponse, 0, len(batch))
+			for _, raw := range batch {
+				if resp := registry.call(c, raw); resp != nil {
+					responses = append(responses, resp)
+				}
+			}
+			if len(responses) == 0 {
+				c.Status(http.StatusNoContent)
+				return
+			}
+			c.rpcReply(responses)
+			return
+		}
+
+		var probe interface{}
+		if err := codec.Unmarshal(body, &probe); err != nil {
+			c.rpcReply(rpcFailure(rpcNullID, RPCParseError, "Parse error"))
+			return
+		}
+		if resp := registry.call(c, body); resp != nil {
+			c.rpcReply(resp)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (c *Context) rpcReply(v interface{}) {
+	data, err := c.engine.json.Marshal(v)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.SetHeader("Content-Type", "application/json")
+	c.Status(http.StatusOK)
+	c.Writer.Write(data)
+}
+
+func rpcFailure(id json.RawMessage, code int, message string) *rpcResponse {
+	return &rpcResponse{JSONRPC: "2.0", Error: &RPCError{Code: code, Message: message}, ID: id}
+}
+
+// call runs one request of raw, it returns nil for a notification
+func (registry *RPCRegistry) call(c *Context, raw json.RawMessage) *rpcResponse {
+	codec := c.engine.json
+	var req rpcRequest
+	if err := codec.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" || !validRPCID(req.ID) {
+		return rpcFailure(rpcNullID, RPCInvalidRequest, "Invalid Request")
+	}
+	notification := req.ID == nil
+
+	resp := registry.invoke(c, &req)
+	if notification {
+		return nil
+	}
+	resp.JSONRPC = "2.0"
+	resp.ID = req.ID
+	return resp
+}
+
+// validRPCID accepts a missing id, a string, a number or null
+func validRPCID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	switch id[0] {
+	case '"', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', 'n':
+		return true
+	}
+	return false
+}
+
+// invoke decodes the params and runs the middlewares and the method in a Context of their own
+func (registry *RPCRegistry) invoke(c *Context, req *rpcRequest) (resp *rpcResponse) {
+	method, common := registry.lookup(req.Method)
+	if method == nil {
+		return &rpcResponse{Error: &RPCError{Code: RPCMethodNotFound, Message: "Method not found"}}
+	}
+
+	args := []reflect.Value{reflect.Value{}}
+	if method.params != nil {
+		params, err := decodeRPCParams(c.engine.json, req.Params, method.params)
+		if err != nil {
+			return &rpcResponse{Error: &RPCError{Code: RPCInvalidParams, Message: "Invalid params", Data: err.Error()}}
+		}
+		args = append(args, params)
+	}
+
+	sub := newContext(&discardWriter{header: make(http.Header)}, c.Req)
+	sub.engine = c.engine
+	sub.Params = c.Params
+	sub.fullPath = c.fullPath + "#" + req.Method
+	if c.Keys == nil {
+		c.Keys = make(map[string]interface{})
+	}
+	sub.Keys = c.Keys
+
+	called := false
+	handlers := make([]HandlerFunc, 0, len(common)+len(method.middlewares)+1)
+	handlers = append(handlers, common...)
+	handlers = append(handlers, method.middlewares...)
+	sub.handlers = append(handlers, func(sub *Context) {
+		called = true
+		args[0] = reflect.ValueOf(sub)
+		out := method.fn.Call(args)
+		if err, _ := out[1].Interface().(error); err != nil {
+			resp = &rpcResponse{Error: sub.rpcError(req.Method, err)}
+			return
+		}
+		result, err := sub.engine.json.Marshal(out[0].Interface())
+		if err != nil {
+			resp = &rpcResponse{Error: sub.rpcError(req.Method, err)}
+			return
+		}
+		resp = &rpcResponse{Result: result}
+	})
+
+	defer func() {
+		if err := recover(); err != nil {
+			c.engine.logger.Printf("rpc %s: panic: %v", req.Method, err)
+			resp = &rpcResponse{Error: &RPCError{Code: RPCInternalError, Message: "Internal error"}}
+		}
+	}()
+	sub.Next()
+
+	if !called {
+		// a middleware answered instead, eg. 401 of an auth middleware
+		status, message := sub.writer.Status(), ""
+		for _, e := range sub.Errors {
+			var httpErr *HTTPError
+			if errors.As(e.Err, &httpErr) {
+				status, message = httpErr.Status, httpErr.Error()
+			}
+		}
+		if status == 0 {
+			status = http.StatusForbidden
+		}
+		if message == "" {
+			message = http.StatusText(status)
+		}
+		return &rpcResponse{Error: &RPCError{Code: RPCServerError, Message: message, Data: H{"status": status}}}
+	}
+	return resp
+}
+
+// discardWriter is the writer of a call, it keeps the headers and the status
+// a middleware answers with and drops the body
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *discardWriter) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(data), nil
+}
+
+// rpcError keeps RPCErrors, any other error is logged and hidden
+func (c *Context) rpcError(method string, err error) *RPCError {
+	if rpcErr, ok := err.(*RPCError); ok {
+		return rpcErr
+	}
+	c.engine.logger.Printf("rpc %s: %v", method, err)
+	return &RPCError{Code: RPCInternalError, Message: "Internal error"}
+}
+
+// decodeRPCParams decodes params into a new value of t, an array fills the fields
+// of a struct in order, missing params leave the zero value
+func decodeRPCParams(codec JSONCodec, params json.RawMessage, t reflect.Type) (reflect.Value, error) {
+	ptr := reflect.New(t)
+	if len(params) == 0 || string(params) == "null" {
+		return ptr.Elem(), nil
+	}
+	if params[0] != '{' && params[0] != '[' {
+		return reflect.Value{}, fmt.Errorf("params must be an object or an array")
+	}
+
+	st := t
+	for st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+	if params[0] != '[' || st.Kind() != reflect.Struct {
+		if err := codec.Unmarshal(params, ptr.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		return ptr.Elem(), nil
+	}
+
+	var values []json.RawMessage
+	if err := codec.Unmarshal(params, &values); err != nil {
+		return reflect.Value{}, err
+	}
+	target := ptr.Elem()
+	for target.Kind() == reflect.Ptr {
+		target.Set(reflect.New(target.Type().Elem()))
+		target = target.Elem()
+	}
+	next := 0
+	for i := 0; i < st.NumField() && next < len(values); i++ {
+		if st.Field(i).PkgPath != "" {
+			continue // unexported
+		}
+		if err := codec.Unmarshal(values[next], target.Field(i).Addr().Interface()); err != nil {
+			return reflect.Value{}, fmt.Errorf("param %d: %v", next, err)
+		}
+		next++
+	}
+	if next < len(values) {
+		return reflect.Value{}, fmt.Errorf("too many params, %d expected", next)
+	}
+	return ptr.Elem(), nil
+}
@@ -0,0 +1,110 @@
+package gee
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type addParams struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+func newRPCEngine(calls *[]string) *Engine {
+	registry := NewRPCRegistry()
+	registry.Use(func(c *Context) {
+		*calls = append(*calls, c.FullPath())
+		c.Next()
+	})
+	registry.Register("add", func(c *Context, p addParams) (int, error) {
+		return p.A + p.B, nil
+	})
+	registry.Register("ping", func(c *Context) (string, error) {
+		return "pong", nil
+	})
+	registry.Register("fail", func(c *Context, p addParams) (int, error) {
+		return 0, &RPCError{Code: 42, Message: "no luck"}
+	})
+	registry.Register("crash", func(c *Context) (int, error) {
+		panic("boom")
+	})
+	registry.Register("secret", func(c *Context) (string, error) {
+		return "42", nil
+	}, func(c *Context) {
+		if c.Req.Header.Get("Authorization") == "" {
+			c.AbortWithStatus(401)
+			return
+		}
+		c.Next()
+	})
+
+	r := New(WithMode(TestMode))
+	r.POST("/rpc", JSONRPC(registry))
+	return r
+}
+
+func rpc(r *Engine, body string) *httptest.ResponseRecorder {
+	return r.Perform(httptest.NewRequest("POST", "/rpc", strings.NewReader(body)))
+}
+
+func TestJSONRPC(t *testing.T) {
+	var calls []string
+	r := newRPCEngine(&calls)
+	tests := map[string]string{
+		`{"jsonrpc":"2.0","method":"add","params":{"a":1,"b":2},"id":1}`: `{"jsonrpc":"2.0","result":3,"id":1}`,
+		`{"jsonrpc":"2.0","method":"add","params":[3,4],"id":"x"}`:       `{"jsonrpc":"2.0","result":7,"id":"x"}`,
+		`{"jsonrpc":"2.0","method":"ping","id":null}`:                    `{"jsonrpc":"2.0","result":"pong","id":null}`,
+		`{"jsonrpc":"2.0","method":"add","params":[1,2,3],"id":1}`:       `"code":-32602`,
+		`{"jsonrpc":"2.0","method":"add","params":{"a":"one"},"id":1}`:   `"code":-32602`,
+		`{"jsonrpc":"2.0","method":"nope","id":1}`:                       `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":1}`,
+		`{"jsonrpc":"1.0","method":"add","id":1}`:                        `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`,
+		`{"jsonrpc":"2.0","method":"add",`:                               `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`,
+		`{"jsonrpc":"2.0","method":"fail","id":1}`:                       `{"jsonrpc":"2.0","error":{"code":42,"message":"no luck"},"id":1}`,
+		`{"jsonrpc":"2.0","method":"crash","id":1}`:                      `{"jsonrpc":"2.0","error":{"code":-32603,"message":"Internal error"},"id":1}`,
+		`{"jsonrpc":"2.0","method":"secret","id":1}`:                     `"code":-32000,"message":"Unauthorized"`,
+		`[]`: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`,
+	}
+	for body, want := range tests {
+		w := rpc(r, body)
+		if w.Code != 200 || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s: status = %d, body = %s, want %s", body, w.Code, w.Body.String(), want)
+		}
+	}
+	found := false
+	for _, path := range calls {
+		found = found || path == "/rpc#add"
+	}
+	if !found {
+		t.Fatalf("FullPath in the method chains = %v, want /rpc#add among them", calls)
+	}
+}
+
+func TestJSONRPCBatch(t *testing.T) {
+	var calls []string
+	r := newRPCEngine(&calls)
+	w := rpc(r, `[
+		{"jsonrpc":"2.0","method":"add","params":[1,1],"id":1},
+		{"jsonrpc":"2.0","method":"ping"},
+		{"jsonrpc":"2.0","method":"nope","id":2},
+		1
+	]`)
+	var responses []rpcResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+		t.Fatalf("%v: %s", err, w.Body.String())
+	}
+	if len(responses) != 3 {
+		t.Fatalf("responses = %s, the notification must not be answered", w.Body.String())
+	}
+	if string(responses[0].Result) != "2" || responses[1].Error.Code != RPCMethodNotFound || responses[2].Error.Code != RPCInvalidRequest {
+		t.Fatalf("responses = %s", w.Body.String())
+	}
+	if len(calls) != 2 {
+		t.Fatalf("the notification should still run: %v", calls)
+	}
+
+	if w := rpc(r, `[{"jsonrpc":"2.0","method":"ping"},{"jsonrpc":"2.0","method":"add","params":[1,2]}]`); w.Code != 204 || w.Body.Len() != 0 {
+		t.Fatalf("notifications only: status = %d, body = %s", w.Code, w.Body.String())
+	}
+}
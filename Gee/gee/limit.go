@@ -0,0 +1,186 @@
+package gee
+
+import (
+	"container/list"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AdaptiveLimitConfig configures AdaptiveConcurrencyLimit. The limit grows by
+// one per limit requests finished within Latency (additive increase) and is
+// multiplied by Backoff when a request takes longer (multiplicative decrease),
+// at most once for the requests started before the last decrease
+type AdaptiveLimitConfig struct {
+	Initial int           // starting limit, defaults to Min
+	Min     int           // lowest limit, at least 1
+	Max     int           // highest limit, 0 means no bound
+	Queue   int           // requests waiting for a slot
+	Wait    time.Duration // longest wait in the queue
+	Latency time.Duration // latency target, slower requests shrink the limit
+	Backoff float64       // factor of the decrease, defaults to 0.9
+}
+
+// waiter is a queued request, granted and generation are set under the limiter lock
+type waiter struct {
+	ready      chan struct{}
+	granted    bool
+	generation uint64
+}
+
+// limiter counts the handlers in flight and queues the requests over the limit
+type limiter struct {
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	waiters  *list.List
+	queue    int
+	wait     time.Duration
+
+	adaptive bool
+	min, max float64
+	latency  time.Duration
+	backoff  float64
+	// generation counts the decreases, a slow request started before the
+	// last one was already accounted for by it
+	generation uint64
+}
+
+// acquire takes a slot, waiting in the queue if it has room, and reports whether it got one
+// and the generation it got it in
+func (l *limiter) acquire(done <-chan struct{}) (uint64, bool) {
+	l.mu.Lock()
+	if l.inFlight < int(l.limit) && l.waiters.Len() == 0 {
+		l.inFlight++
+		generation := l.generation
+		l.mu.Unlock()
+		return generation, true
+	}
+	if l.waiters.Len() >= l.queue || l.wait <= 0 {
+		l.mu.Unlock()
+		return 0, false
+	}
+	w := &waiter{ready: make(chan struct{})}
+	elem := l.waiters.PushBack(w)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+		return w.generation, true
+	case <-timer.C:
+	case <-done:
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.granted {
+		// the slot came along with the timeout, keep it
+		return w.generation, true
+	}
+	l.waiters.Remove(elem)
+	return 0, false
+}
+
+// release frees a slot taken in generation, adapts the limit to the latency
+// and hands the free slots to the queue
+func (l *limiter) release(generation uint64, latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	if l.adaptive {
+		if latency > l.latency {
+			if generation == l.generation {
+				l.limit = math.Max(l.min, l.limit*l.backoff)
+				l.generation++
+			}
+		} else {
+			l.limit += 1 / l.limit
+			if l.max > 0 {
+				l.limit = math.Min(l.max, l.limit)
+			}
+		}
+	}
+	for l.inFlight < int(l.limit) && l.waiters.Len() > 0 {
+		w := l.waiters.Remove(l.waiters.Front()).(*waiter)
+		w.granted = true
+		w.generation = l.generation
+		l.inFlight++
+		close(w.ready)
+	}
+}
+
+// current returns the limit in effect
+func (l *limiter) current() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+func (l *limiter) handle(c *Context) {
+	generation, ok := l.acquire(c.Req.Context().Done())
+	if !ok {
+		retry := int(math.Ceil(l.wait.Seconds()))
+		if retry < 1 {
+			retry = 1
+		}
+		c.SetHeader("Retry-After", strconv.Itoa(retry))
+		c.String(http.StatusServiceUnavailable, "503 SERVICE UNAVAILABLE\n")
+		c.Abort()
+		return
+	}
+	start := time.Now()
+	defer func() {
+		l.release(generation, time.Since(start))
+	}()
+	c.Next()
+}
+
+// ConcurrencyLimit runs at most n handlers of the group or route at a time,
+// up to queue more requests wait at most wait for a slot and the rest
+// are shed with 503 and Retry-After. Every call makes a separate bulkhead
+func ConcurrencyLimit(n, queue int, wait time.Duration) HandlerFunc {
+	if n < 1 {
+		panic("gee: ConcurrencyLimit needs a limit of at least 1")
+	}
+	l := &limiter{limit: float64(n), waiters: list.New(), queue: queue, wait: wait}
+	return l.handle
+}
+
+// AdaptiveConcurrencyLimit is ConcurrencyLimit with a limit adjusted from the
+// observed latency (AIMD), so an overloaded backend is shed automatically
+func AdaptiveConcurrencyLimit(config AdaptiveLimitConfig) HandlerFunc {
+	return newAdaptiveLimiter(config).handle
+}
+
+func newAdaptiveLimiter(config AdaptiveLimitConfig) *limiter {
+	if config.Min < 1 {
+		config.Min = 1
+	}
+	if config.Initial < config.Min {
+		config.Initial = config.Min
+	}
+	if config.Max > 0 && config.Initial > config.Max {
+		config.Initial = config.Max
+	}
+	if config.Backoff <= 0 || config.Backoff >= 1 {
+		config.Backoff = 0.9
+	}
+	if config.Latency <= 0 {
+		panic("gee: AdaptiveConcurrencyLimit needs a latency target")
+	}
+	return &limiter{
+		limit:    float64(config.Initial),
+		waiters:  list.New(),
+		queue:    config.Queue,
+		wait:     config.Wait,
+		adaptive: true,
+		min:      float64(config.Min),
+		max:      float64(config.Max),
+		latency:  config.Latency,
+		backoff:  config.Backoff,
+	}
+}
@@ -0,0 +1,113 @@
+package gee
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingEngine serves GET / with limit, the handler waits for release
+func blockingEngine(limit HandlerFunc, release chan struct{}, started chan struct{}) *Engine {
+	r := New(WithMode(TestMode))
+	r.GET("/", limit, func(c *Context) {
+		started <- struct{}{}
+		<-release
+		c.String(200, "ok")
+	})
+	return r
+}
+
+func TestConcurrencyLimitSheds(t *testing.T) {
+	release, started := make(chan struct{}), make(chan struct{}, 2)
+	r := blockingEngine(ConcurrencyLimit(1, 0, time.Second), release, started)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.Perform(httptest.NewRequest("GET", "/", nil))
+	}()
+	<-started
+
+	w := r.Perform(httptest.NewRequest("GET", "/", nil))
+	if w.Code != 503 || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("status = %d, Retry-After = %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestConcurrencyLimitQueue(t *testing.T) {
+	release, started := make(chan struct{}), make(chan struct{}, 2)
+	r := blockingEngine(ConcurrencyLimit(1, 1, time.Second), release, started)
+
+	codes := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			codes <- r.Perform(httptest.NewRequest("GET", "/", nil)).Code
+		}()
+	}
+	<-started
+	select {
+	case <-started:
+		t.Fatal("the second request should wait for the first")
+	case <-time.After(20 * time.Millisecond):
+	}
+	release <- struct{}{}
+	<-started // the queued request got the slot
+	close(release)
+	if a, b := <-codes, <-codes; a != 200 || b != 200 {
+		t.Fatalf("codes = %d, %d", a, b)
+	}
+}
+
+func TestConcurrencyLimitWaitTimeout(t *testing.T) {
+	release, started := make(chan struct{}), make(chan struct{}, 2)
+	r := blockingEngine(ConcurrencyLimit(1, 1, 10*time.Millisecond), release, started)
+
+	done := make(chan struct{})
+	go func() {
+		r.Perform(httptest.NewRequest("GET", "/", nil))
+		close(done)
+	}()
+	<-started
+	if w := r.Perform(httptest.NewRequest("GET", "/", nil)); w.Code != 503 {
+		t.Fatalf("status = %d", w.Code)
+	}
+	close(release)
+	<-done
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	l := newAdaptiveLimiter(AdaptiveLimitConfig{Initial: 10, Min: 2, Max: 11, Latency: 50 * time.Millisecond})
+
+	for i := 0; i < 100; i++ {
+		generation, _ := l.acquire(nil)
+		l.release(generation, time.Millisecond)
+	}
+	if got := l.current(); got != 11 {
+		t.Fatalf("limit after fast requests = %d, want the max 11", got)
+	}
+
+	// slow requests started together shrink the limit once
+	var generations []uint64
+	for i := 0; i < 10; i++ {
+		generation, _ := l.acquire(nil)
+		generations = append(generations, generation)
+	}
+	for _, generation := range generations {
+		l.release(generation, time.Second)
+	}
+	if got := l.current(); got != 9 {
+		t.Fatalf("limit after a burst of slow requests = %d, want 9", got)
+	}
+
+	for i := 0; i < 100; i++ {
+		generation, _ := l.acquire(nil)
+		l.release(generation, time.Second)
+	}
+	if got := l.current(); got != 2 {
+		t.Fatalf("limit after slow requests = %d, want the min 2", got)
+	}
+}
@@ -1,7 +1,6 @@
 package gee
 
 import (
-	"log"
 	"time"
 )
 
@@ -12,6 +11,6 @@ func Logger() HandlerFunc{
 		// process request
 		c.Next()
 		// calculate resolution time
-		log.Printf("[%d] %s in %v", c.StatusCode, c.Req.RequestURI, time.Since(t))
+		c.engine.logger.Printf("[%d] %s in %v", c.StatusCode, c.Req.RequestURI, time.Since(t))
 	}
 }
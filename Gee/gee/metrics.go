@@ -0,0 +1,198 @@
+package gee
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// DurationBuckets are the upper bounds in seconds of the request latency histogram
+	DurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// SizeBuckets are the upper bounds in bytes of the response size histogram
+	SizeBuckets = []float64{100, 1000, 10000, 100000, 1e6, 1e7}
+)
+
+// metricLabels uses the route pattern instead of the path, which keeps the number of series bounded
+type metricLabels struct {
+	method string
+	route  string
+	status int
+}
+
+type histogram struct {
+	bounds []float64
+	counts []uint64 // counts[i] observations <= bounds[i], not cumulative
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+type requestMetrics struct {
+	duration *histogram
+	size     *histogram
+}
+
+// metrics holds the request metrics of one engine
+type metrics struct {
+	mu       sync.Mutex
+	requests map[metricLabels]*requestMetrics
+	inFlight int64
+}
+
+func newMetrics() *metrics {
+	return &metrics{requests: make(map[metricLabels]*requestMetrics)}
+}
+
+func (m *metrics) observe(labels metricLabels, duration time.Duration, size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.requests[labels]
+	if !ok {
+		r = &requestMetrics{duration: newHistogram(DurationBuckets), size: newHistogram(SizeBuckets)}
+		m.requests[labels] = r
+	}
+	r.duration.observe(duration.Seconds())
+	r.size.observe(float64(size))
+}
+
+// Metrics records the count, latency and response size of every request
+// labelled by method, route pattern and status, and the requests in flight
+func Metrics() HandlerFunc {
+	return func(c *Context) {
+		m := c.engine.metrics
+		atomic.AddInt64(&m.inFlight, 1)
+		start := time.Now()
+		defer func() {
+			atomic.AddInt64(&m.inFlight, -1)
+			route := c.FullPath()
+			if route == "" {
+				route = "unmatched"
+			}
+			status := c.writer.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			m.observe(metricLabels{method: methodLabel(c.Method), route: route, status: status}, time.Since(start), c.writer.Size())
+		}()
+		c.Next()
+	}
+}
+
+// methodLabel keeps the label set bounded, clients can send any method
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
+
+// ServeMetrics serves the recorded metrics in the Prometheus text format from path
+func (group *RouterGroup) ServeMetrics(path string) *Route {
+	m := group.engine.metrics
+	route := group.GET(path, func(c *Context) {
+		c.SetHeader("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		c.Writer.Write(m.expose())
+	})
+	route.hidden = true
+	return route
+}
+
+// expose encodes the metrics in the Prometheus text exposition format
+func (m *metrics) expose() []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]metricLabels, 0, len(m.requests))
+	for labels := range m.requests {
+		keys = append(keys, labels)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+
+	buf := new(bytes.Buffer)
+	writeHeader(buf, "gee_http_requests_total", "counter", "Total number of HTTP requests.")
+	for _, labels := range keys {
+		fmt.Fprintf(buf, "gee_http_requests_total{%s} %d\n", labels.format(), m.requests[labels].duration.count)
+	}
+	writeHeader(buf, "gee_http_request_duration_seconds", "histogram", "HTTP request latency in seconds.")
+	for _, labels := range keys {
+		writeHistogram(buf, "gee_http_request_duration_seconds", labels.format(), m.requests[labels].duration)
+	}
+	writeHeader(buf, "gee_http_response_size_bytes", "histogram", "HTTP response body size in bytes.")
+	for _, labels := range keys {
+		writeHistogram(buf, "gee_http_response_size_bytes", labels.format(), m.requests[labels].size)
+	}
+	writeHeader(buf, "gee_http_requests_in_flight", "gauge", "Number of HTTP requests being served.")
+	fmt.Fprintf(buf, "gee_http_requests_in_flight %d\n", atomic.LoadInt64(&m.inFlight))
+	return buf.Bytes()
+}
+
+func writeHeader(buf *bytes.Buffer, name, typ, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeHistogram(buf *bytes.Buffer, name, labels string, h *histogram) {
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(buf, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(bound), cumulative)
+	}
+	fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(buf, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(buf, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+func (l metricLabels) format() string {
+	return fmt.Sprintf(`method="%s",route="%s",status="%d"`, escapeLabel(l.method), escapeLabel(l.route), l.status)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
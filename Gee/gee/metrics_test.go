@@ -0,0 +1,52 @@
+package gee
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	r := New()
+	r.Use(Metrics())
+	r.GET("/users/:id", func(c *Context) {
+		c.String(200, "user %s", c.Param("id"))
+	})
+	r.ServeMetrics("/metrics")
+
+	r.Perform(httptest.NewRequest("GET", "/users/1", nil))
+	r.Perform(httptest.NewRequest("GET", "/users/2", nil))
+	r.Perform(httptest.NewRequest("GET", "/missing", nil))
+	r.Perform(httptest.NewRequest("FOO1", "/missing", nil))
+	r.Perform(httptest.NewRequest("FOO2", "/missing", nil))
+
+	w := r.Perform(httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		"# TYPE gee_http_requests_total counter",
+		`gee_http_requests_total{method="GET",route="/users/:id",status="200"} 2`,
+		`gee_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`gee_http_requests_total{method="other",route="unmatched",status="404"} 2`,
+		`gee_http_response_size_bytes_bucket{method="GET",route="/users/:id",status="200",le="100"} 2`,
+		`gee_http_response_size_bytes_sum{method="GET",route="/users/:id",status="200"} 12`,
+		`gee_http_request_duration_seconds_count{method="GET",route="/users/:id",status="200"} 2`,
+		// the /metrics request itself is in flight
+		"gee_http_requests_in_flight 1",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "/users/1") {
+		t.Error("raw paths must not be used as labels")
+	}
+	if strings.Contains(body, "FOO1") {
+		t.Error("unknown methods must not be used as labels")
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got := escapeLabel("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Fatalf("escapeLabel = %s", got)
+	}
+}
@@ -0,0 +1,29 @@
+package gee
+
+const (
+	// DebugMode logs routes, reloads changed templates and shows template errors in the browser
+	DebugMode = "debug"
+	// ReleaseMode parses templates once, skips the debug logs and only logs render errors
+	ReleaseMode = "release"
+	// TestMode behaves like ReleaseMode, but discards all logs unless a logger is set
+	TestMode = "test"
+)
+
+// SetMode switches the engine between DebugMode, ReleaseMode and TestMode
+func (engine *Engine) SetMode(mode string) {
+	switch mode {
+	case DebugMode, ReleaseMode, TestMode:
+		engine.mode = mode
+	default:
+		panic("gee: unknown mode " + mode)
+	}
+}
+
+// Mode returns the current mode of the engine
+func (engine *Engine) Mode() string {
+	return engine.mode
+}
+
+func (engine *Engine) isDebug() bool {
+	return engine.mode == DebugMode
+}
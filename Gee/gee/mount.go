@@ -0,0 +1,111 @@
+package gee
+
+import "strings"
+
+// mount is an engine mounted under a prefix
+type mount struct {
+	prefix string
+	engine *Engine
+	enter  HandlerFunc
+}
+
+// Mount serves the routes of child under prefix, eg. api.Mount("/admin", admin).
+// Each route runs the middlewares of the groups of child it belongs to after
+// the ones of the parent, the child's middlewares never run for other routes.
+// Handlers of child see child as their engine, so its templates, JSON codec and
+// logger are used, and its NoRoute and NoMethod handlers answer unmatched paths
+// under prefix. The routes are copied with their names prefixed by the mount
+// prefix, eg. user mounted at /admin is named admin.user, so mount child once it
+// is set up. Routes of child's Host groups are not mounted
+func (group *RouterGroup) Mount(prefix string, child *Engine) *RouterGroup {
+	if child == group.engine {
+		panic("gee: an engine can not be mounted into itself")
+	}
+	mounted := group.Group(prefix)
+	m := &mount{prefix: mounted.prefix, engine: child, enter: enterEngine(child)}
+	mounted.router().update(func(t *routeTree, inPlace bool) {
+		t.mounts = append(t.mounts, m)
+	})
+
+	namePrefix := strings.ReplaceAll(strings.Trim(prefix, "/"), "/", ".")
+	if namePrefix != "" {
+		namePrefix += "."
+	}
+
+	child.mu.RLock()
+	routes := append([]*Route(nil), child.routes...)
+	child.mu.RUnlock()
+	for _, route := range routes {
+		if route.host != nil {
+			continue
+		}
+		handlers := []HandlerFunc{m.enter}
+		handlers = append(handlers, child.middlewaresFor(route.Pattern, nil)...)
+		handlers = append(handlers, route.handlers...)
+
+		copied := mounted.addRoute(route.Method, route.Pattern, handlers)
+		copied.summary = route.summary
+		copied.description = route.description
+		copied.tags = route.tags
+		copied.request = route.request
+		copied.responses = route.responses
+		copied.hidden = route.hidden
+		if route.name != "" {
+			name := namePrefix + route.name
+			engine := group.engine
+			engine.mu.RLock()
+			old, taken := engine.namedRoutes[name]
+			engine.mu.RUnlock()
+			if taken && old != copied {
+				// eg. a child mounted at / using a name of the parent
+				engine.logger.Printf("mount %s: route name %q is already used by %s, %s is not named", mounted.prefix, name, old.Pattern, copied.Pattern)
+				continue
+			}
+			copied.Name(name)
+		}
+	}
+	return mounted
+}
+
+// enterEngine makes child the engine of the request for the rest of the chain
+func enterEngine(child *Engine) HandlerFunc {
+	return func(c *Context) {
+		parent := c.engine
+		c.engine = child
+		if child.maxBodySize > 0 {
+			c.limitBody(child.maxBodySize)
+		}
+		c.Next()
+		c.engine = parent
+	}
+}
+
+// middlewaresFor collects the middlewares of the groups matching path
+func (engine *Engine) middlewaresFor(path string, host *hostRoute) []HandlerFunc {
+	var middlewares []HandlerFunc
+	for _, group := range engine.loadState().groups {
+		if (group.host == nil || group.host == host) && strings.HasPrefix(path, group.prefix) {
+			middlewares = append(middlewares, group.middlewares...)
+		}
+	}
+	return middlewares
+}
+
+// unmatched returns the NoRoute or, with noMethod, the NoMethod handlers of engine for path,
+// under the prefix of a mounted engine they are the ones of that engine
+func (t *routeTree) unmatched(engine *Engine, path string, noMethod bool) []HandlerFunc {
+	for i := len(t.mounts) - 1; i >= 0; i-- {
+		m := t.mounts[i]
+		if path != m.prefix && !strings.HasPrefix(path, m.prefix+"/") {
+			continue
+		}
+		rest := strings.TrimPrefix(path, m.prefix)
+		handlers := []HandlerFunc{m.enter}
+		handlers = append(handlers, m.engine.middlewaresFor(rest, nil)...)
+		return append(handlers, m.engine.router.snapshot().unmatched(m.engine, rest, noMethod)...)
+	}
+	if noMethod {
+		return engine.noMethod
+	}
+	return engine.noRoute
+}
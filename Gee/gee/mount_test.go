@@ -0,0 +1,103 @@
+package gee
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func newAdminEngine(trace *[]string) *Engine {
+	admin := New(WithMode(TestMode), WithJSONCodec(upperCodec{}))
+	admin.Use(func(c *Context) {
+		*trace = append(*trace, "admin")
+	})
+	admin.GET("/users/:id", func(c *Context) {
+		c.JSON(200, H{"id": c.Param("id")})
+	}).Name("user")
+	admin.NoRoute(func(c *Context) {
+		c.String(404, "admin: no route")
+	})
+	return admin
+}
+
+func TestMount(t *testing.T) {
+	var trace []string
+	r := New(WithMode(TestMode))
+	r.Use(func(c *Context) {
+		trace = append(trace, "parent")
+	})
+	r.GET("/other", func(c *Context) {
+		c.String(200, "other")
+	})
+	r.Mount("/admin", newAdminEngine(&trace))
+
+	w := r.Perform(httptest.NewRequest("GET", "/admin/users/1", nil))
+	// the child's JSON codec renders the response
+	if w.Code != 200 || w.Body.String() != "\"CUSTOM\"\n" {
+		t.Fatalf("status = %d, body = %q", w.Code, w.Body.String())
+	}
+	if len(trace) != 2 || trace[0] != "parent" || trace[1] != "admin" {
+		t.Fatalf("middlewares = %v", trace)
+	}
+
+	trace = nil
+	r.Perform(httptest.NewRequest("GET", "/other", nil))
+	if len(trace) != 1 {
+		t.Fatalf("the child's middlewares ran for a parent route: %v", trace)
+	}
+
+	if url, err := r.URL("admin.user", 7); err != nil || url != "/admin/users/7" {
+		t.Fatalf("URL = %q, %v", url, err)
+	}
+}
+
+func TestMountNoRoute(t *testing.T) {
+	var trace []string
+	r := New(WithMode(TestMode))
+	r.Mount("/admin", newAdminEngine(&trace))
+
+	if w := r.Perform(httptest.NewRequest("GET", "/admin/missing", nil)); w.Code != 404 || w.Body.String() != "admin: no route" {
+		t.Fatalf("status = %d, body = %q", w.Code, w.Body.String())
+	}
+	if w := r.Perform(httptest.NewRequest("GET", "/missing", nil)); w.Body.String() == "admin: no route" {
+		t.Fatal("the child's NoRoute answered outside its prefix")
+	}
+}
+
+func TestMountNested(t *testing.T) {
+	var trace []string
+	api := New(WithMode(TestMode))
+	api.Mount("/admin", newAdminEngine(&trace))
+	r := New(WithMode(TestMode))
+	r.Group("/api").Mount("/v1", api)
+
+	if w := r.Perform(httptest.NewRequest("GET", "/api/v1/admin/users/1", nil)); w.Code != 200 {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if w := r.Perform(httptest.NewRequest("GET", "/api/v1/admin/nope", nil)); w.Body.String() != "admin: no route" {
+		t.Fatalf("body = %q", w.Body.String())
+	}
+	if url, err := r.URL("v1.admin.user", 7); err != nil || url != "/api/v1/admin/users/7" {
+		t.Fatalf("URL = %q, %v", url, err)
+	}
+}
+
+func TestMountSameRouteNames(t *testing.T) {
+	module := func(name string) *Engine {
+		e := New(WithMode(TestMode))
+		e.GET("/", func(c *Context) {
+			c.String(200, name)
+		}).Name("home")
+		return e
+	}
+	r := New(WithMode(TestMode))
+	r.GET("/about", func(c *Context) {}).Name("home")
+	r.Mount("/admin", module("admin"))
+	r.Mount("/billing", module("billing"))
+	r.Mount("/", module("root"))
+
+	for name, want := range map[string]string{"home": "/about", "admin.home": "/admin/", "billing.home": "/billing/"} {
+		if url, err := r.URL(name); err != nil || url != want {
+			t.Errorf("URL(%q) = %q, %v, want %q", name, url, err, want)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package gee
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIInfo is the info object of the generated OpenAPI document
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// openAPISchemas holds the component schemas of named structs. Types of different
+// packages may share a name, the later ones get a number appended, eg. User2
+type openAPISchemas struct {
+	components H
+	names      map[reflect.Type]string
+}
+
+// name returns the component name of t, added reports whether t was new
+func (schemas *openAPISchemas) name(t reflect.Type) (name string, added bool) {
+	if name, ok := schemas.names[t]; ok {
+		return name, false
+	}
+	name = t.Name()
+	for i := 2; schemas.components[name] != nil; i++ {
+		name = t.Name() + strconv.Itoa(i)
+	}
+	schemas.names[t] = name
+	schemas.components[name] = H{} // placeholder for recursive types
+	return name, true
+}
+
+// OpenAPI builds an OpenAPI 3 document from the registered routes and their metadata
+func (engine *Engine) OpenAPI(info OpenAPIInfo) H {
+	schemas := &openAPISchemas{components: H{}, names: make(map[reflect.Type]string)}
+	paths := H{}
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+	for _, route := range engine.routes {
+		if route.hidden {
+			continue
+		}
+		path, params := openAPIPath(route.Pattern)
+		item, ok := paths[path].(H)
+		if !ok {
+			item = H{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = openAPIOperation(route, params, schemas)
+	}
+
+	doc := H{
+		"openapi": "3.0.3",
+		"info":    info,
+		"paths":   paths,
+	}
+	if len(schemas.components) > 0 {
+		doc["components"] = H{"schemas": schemas.components}
+	}
+	return doc
+}
+
+// ServeOpenAPI serves the OpenAPI document as JSON from path, the document
+// is built on each request so routes added later are included
+func (group *RouterGroup) ServeOpenAPI(path string, info OpenAPIInfo) *Route {
+	engine := group.engine
+	route := group.GET(path, func(c *Context) {
+		c.JSON(http.StatusOK, engine.OpenAPI(info))
+	})
+	route.hidden = true
+	return route
+}
+
+// openAPIParam is a path parameter, constraint is the part between < and >
+type openAPIParam struct {
+	name       string
+	constraint string
+}
+
+// openAPIPath turns /users/:id<int>/*filepath into /users/{id}/{filepath}
+func openAPIPath(pattern string) (string, []openAPIParam) {
+	var params []openAPIParam
+	segments := strings.Split(pattern, "/")
+	for i, seg := range segments {
+		if seg == "" || (seg[0] != ':' && seg[0] != '*') {
+			continue
+		}
+		name, constraint := splitParam(seg)
+		params = append(params, openAPIParam{name: name, constraint: constraint})
+		segments[i] = "{" + name + "}"
+		if seg[0] == '*' {
+			segments = segments[:i+1]
+			break
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func openAPIOperation(route *Route, params []openAPIParam, schemas *openAPISchemas) H {
+	op := H{}
+	if route.name != "" {
+		op["operationId"] = route.name
+	}
+	if route.summary != "" {
+		op["summary"] = route.summary
+	}
+	if route.description != "" {
+		op["description"] = route.description
+	}
+	if len(route.tags) > 0 {
+		op["tags"] = route.tags
+	}
+	if len(params) > 0 {
+		parameters := make([]H, 0, len(params))
+		for _, param := range params {
+			parameters = append(parameters, H{
+				"name":     param.name,
+				"in":       "path",
+				"required": true,
+				"schema":   constraintSchema(param.constraint),
+			})
+		}
+		op["parameters"] = parameters
+	}
+	if route.request != nil {
+		op["requestBody"] = H{
+			"required": true,
+			"content":  H{"application/json": H{"schema": schemaOf(route.request, schemas)}},
+		}
+	}
+
+	// in order, so the names of colliding schemas do not change between calls
+	codes := make([]int, 0, len(route.responses))
+	for code := range route.responses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	responses := H{}
+	for _, code := range codes {
+		typ := route.responses[code]
+		response := H{"description": http.StatusText(code)}
+		if typ != nil {
+			response["content"] = H{"application/json": H{"schema": schemaOf(typ, schemas)}}
+		}
+		responses[strconv.Itoa(code)] = response
+	}
+	if len(responses) == 0 {
+		responses["200"] = H{"description": http.StatusText(http.StatusOK)}
+	}
+	op["responses"] = responses
+	return op
+}
+
+func constraintSchema(constraint string) H {
+	switch constraint {
+	case "":
+		return H{"type": "string"}
+	case "int":
+		return H{"type": "integer", "format": "int64"}
+	case "uint":
+		return H{"type": "integer", "format": "int64", "minimum": 0}
+	case "uuid":
+		return H{"type": "string", "format": "uuid"}
+	}
+	expr, ok := constraints[constraint]
+	if !ok {
+		expr = constraint
+	}
+	return H{"type": "string", "pattern": "^(?:" + expr + ")$"}
+}
+
+// schemaOf returns the JSON schema of t, named structs are put into schemas
+// and referenced, which also ends recursion on self referencing types
+func schemaOf(t reflect.Type, schemas *openAPISchemas) H {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return H{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return H{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return H{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return H{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return H{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return H{"type": "number", "format": "double"}
+	case reflect.String:
+		return H{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return H{"type": "string", "format": "byte"}
+		}
+		return H{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return H{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		name, added := schemas.name(t)
+		if added {
+			schemas.components[name] = structSchema(t, schemas)
+		}
+		return H{"$ref": "#/components/schemas/" + name}
+	}
+	return H{}
+}
+
+func structSchema(t reflect.Type, schemas *openAPISchemas) H {
+	properties := H{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		name := field.Name
+		omitempty := false
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			opts := strings.Split(tag, ",")
+			if opts[0] == "-" {
+				continue
+			}
+			if opts[0] != "" {
+				name = opts[0]
+			}
+			for _, opt := range opts[1:] {
+				omitempty = omitempty || opt == "omitempty"
+			}
+		}
+		properties[name] = schemaOf(field.Type, schemas)
+		if !omitempty && field.Type.Kind() != reflect.Ptr {
+			required = append(required, name)
+		}
+	}
+	schema := H{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,73 @@
+package gee
+
+import (
+	"log"
+)
+
+// LogPrinter is where the engine writes its logs, *log.Logger satisfies it
+type LogPrinter interface {
+	Printf(format string, v ...interface{})
+}
+
+// Option configures an Engine in New
+type Option func(*Engine)
+
+// WithMode sets DebugMode, ReleaseMode or TestMode
+func WithMode(mode string) Option {
+	return func(engine *Engine) {
+		engine.SetMode(mode)
+	}
+}
+
+// WithLogger replaces the standard logger for routes, requests, panics and template errors
+func WithLogger(logger LogPrinter) Option {
+	return func(engine *Engine) {
+		engine.logger = logger
+	}
+}
+
+// WithJSONCodec replaces encoding/json for rendering and binding JSON
+func WithJSONCodec(codec JSONCodec) Option {
+	return func(engine *Engine) {
+		engine.json = codec
+	}
+}
+
+// WithMaxBodySize limits every request body to n bytes, 0 means no limit.
+// BodyLimit changes the limit for a group
+func WithMaxBodySize(n int64) Option {
+	return func(engine *Engine) {
+		engine.maxBodySize = n
+	}
+}
+
+// WithTrustedProxies sets the proxies allowed to set forwarding headers, it panics on an invalid entry
+func WithTrustedProxies(proxies ...string) Option {
+	return func(engine *Engine) {
+		if err := engine.SetTrustedProxies(proxies); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// WithRouteLogging turns the log line of every registered route on or off,
+// by default routes are only logged in DebugMode
+func WithRouteLogging(on bool) Option {
+	return func(engine *Engine) {
+		engine.logRoutes = &on
+	}
+}
+
+func (engine *Engine) shouldLogRoutes() bool {
+	if engine.logRoutes != nil {
+		return *engine.logRoutes
+	}
+	return engine.isDebug()
+}
+
+// discardLogger drops everything, it is the logger of TestMode
+type discardLogger struct{}
+
+func (discardLogger) Printf(format string, v ...interface{}) {}
+
+var defaultLogger LogPrinter = log.Default()
@@ -0,0 +1,91 @@
+package gee
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type bufferLogger struct {
+	bytes.Buffer
+}
+
+func (l *bufferLogger) Printf(format string, v ...interface{}) {
+	fmt.Fprintf(&l.Buffer, format+"\n", v...)
+}
+
+func TestRouteLoggingByMode(t *testing.T) {
+	debug := &bufferLogger{}
+	r := New(WithLogger(debug))
+	r.GET("/hello", func(c *Context) {})
+	if !strings.Contains(debug.String(), "Route  GET - /hello") {
+		t.Fatalf("debug mode should log routes, got %q", debug.String())
+	}
+
+	release := &bufferLogger{}
+	r = New(WithMode(ReleaseMode), WithLogger(release))
+	r.GET("/hello", func(c *Context) {})
+	if release.Len() != 0 {
+		t.Fatalf("release mode should not log routes, got %q", release.String())
+	}
+
+	r = New(WithMode(ReleaseMode), WithLogger(release), WithRouteLogging(true))
+	r.GET("/hello", func(c *Context) {})
+	if release.Len() == 0 {
+		t.Fatal("WithRouteLogging(true) should log routes")
+	}
+}
+
+func TestTestModeDiscardsLogs(t *testing.T) {
+	r := New(WithMode(TestMode))
+	if _, ok := r.logger.(discardLogger); !ok {
+		t.Fatalf("logger = %T, want discardLogger", r.logger)
+	}
+}
+
+type upperCodec struct {
+	stdJSON
+}
+
+func (upperCodec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(`"CUSTOM"`), nil
+}
+
+func TestWithJSONCodec(t *testing.T) {
+	r := New(WithJSONCodec(upperCodec{}))
+	r.GET("/", func(c *Context) {
+		c.JSON(200, H{"a": 1})
+	})
+	if w := r.Perform(httptest.NewRequest("GET", "/", nil)); w.Body.String() != "\"CUSTOM\"\n" {
+		t.Fatalf("body = %q", w.Body.String())
+	}
+}
+
+func TestWithMaxBodySize(t *testing.T) {
+	r := New(WithMaxBodySize(4))
+	r.POST("/", func(c *Context) {
+		_, err := io.ReadAll(c.Req.Body)
+		c.String(200, "%v", err != nil)
+	})
+	if w := r.Perform(httptest.NewRequest("POST", "/", strings.NewReader("too long"))); w.Code != 413 {
+		t.Fatalf("status = %d, a Content-Length over the limit should be rejected", w.Code)
+	}
+	req := httptest.NewRequest("POST", "/", strings.NewReader("too long"))
+	req.ContentLength = -1
+	if w := r.Perform(req); w.Body.String() != "true" {
+		t.Fatal("reading past the limit should fail")
+	}
+}
+
+func TestWithTrustedProxies(t *testing.T) {
+	r := New(WithTrustedProxies("10.0.0.0/8"))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.1.2.3:80"
+	req.Header.Set("X-Forwarded-For", "8.8.8.8")
+	if ip := r.NewContext(httptest.NewRecorder(), req).ClientIP(); ip != "8.8.8.8" {
+		t.Fatalf("ClientIP = %s", ip)
+	}
+}
@@ -0,0 +1,89 @@
+package gee
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// removeExtraSlash collapses every run of slashes into one
+func removeExtraSlash(p string) string {
+	if !strings.Contains(p, "//") {
+		return p
+	}
+	buf := make([]byte, 0, len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' && len(buf) > 0 && buf[len(buf)-1] == '/' {
+			continue
+		}
+		buf = append(buf, p[i])
+	}
+	return string(buf)
+}
+
+// cleanPath resolves . and .. and removes extra slashes, a trailing slash is kept
+func cleanPath(p string) string {
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// canonicalPath rebuilds path from the matched pattern: static parts are
+// spelled as in the pattern, there are no empty parts, and it ends with a
+// slash only if the pattern does. A wildcard keeps the trailing slash of path
+func canonicalPath(pattern string, p string) string {
+	searchParts := parsePattern(p)
+	parts := parsePattern(pattern)
+	segments := make([]string, 0, len(searchParts))
+	for i, part := range parts {
+		switch part[0] {
+		case '*':
+			segments = append(segments, searchParts[i:]...)
+			canonical := "/" + strings.Join(segments, "/")
+			if strings.HasSuffix(p, "/") {
+				canonical += "/"
+			}
+			return canonical
+		case ':':
+			segments = append(segments, searchParts[i])
+		default:
+			segments = append(segments, part)
+		}
+	}
+	canonical := "/" + strings.Join(segments, "/")
+	if len(segments) > 0 && strings.HasSuffix(pattern, "/") {
+		canonical += "/"
+	}
+	return canonical
+}
+
+func onlyTrailingSlash(a, b string) bool {
+	return a != b && strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+}
+
+// fixedPath cleans p and looks it up case-insensitively, it returns the canonical path of the route found
+func (t *routeTree) fixedPath(method string, p string) (string, bool) {
+	cleaned := cleanPath(p)
+	n, _ := t.findRoute(method, cleaned, true)
+	if n == nil {
+		return "", false
+	}
+	fixed := canonicalPath(n.pattern, cleaned)
+	return fixed, fixed != p
+}
+
+// redirect sends the client to the canonical path, 301 for GET and HEAD and 308
+// for the other methods, so the method and the body are kept
+func redirect(location string) HandlerFunc {
+	return func(c *Context) {
+		code := http.StatusPermanentRedirect
+		if c.Method == http.MethodGet || c.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+		u := &url.URL{Path: location, RawQuery: c.Req.URL.RawQuery}
+		c.Redirect(code, u.String())
+	}
+}
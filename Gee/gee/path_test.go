@@ -0,0 +1,86 @@
+package gee
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func newPathTestEngine(configure func(*Engine)) *Engine {
+	r := New()
+	configure(r)
+	v1 := r.Group("/v1")
+	v1.GET("/hello", func(c *Context) {
+		c.String(200, "hello")
+	})
+	v1.POST("/users/:name", func(c *Context) {
+		c.String(200, "user %s", c.Param("name"))
+	})
+	v1.GET("/dir/", func(c *Context) {
+		c.String(200, "dir")
+	})
+	return r
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := map[string]string{
+		"/a//b/":      "/a/b/",
+		"/a/../b":     "/b",
+		"a/./b":       "/a/b",
+		"/../../a//b": "/a/b",
+		"/":           "/",
+	}
+	for in, want := range tests {
+		if got := cleanPath(in); got != want {
+			t.Errorf("cleanPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	r := newPathTestEngine(func(e *Engine) {
+		e.RedirectTrailingSlash = true
+		e.RedirectFixedPath = true
+	})
+	tests := []struct {
+		method, path string
+		code         int
+		location     string
+	}{
+		{"GET", "/v1/hello/", 301, "/v1/hello"},
+		{"GET", "/v1/dir", 301, "/v1/dir/"},
+		{"GET", "/v1//hello?x=1", 301, "/v1/hello?x=1"},
+		{"GET", "/V1/HELLO", 301, "/v1/hello"},
+		{"GET", "/v1/x/../hello", 301, "/v1/hello"},
+		{"POST", "/V1/Users/Tom", 308, "/v1/users/Tom"},
+		{"GET", "/v1/hello", 200, ""},
+	}
+	for _, tt := range tests {
+		w := r.Perform(httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.code || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s: got %d %q, want %d %q", tt.method, tt.path, w.Code, w.Header().Get("Location"), tt.code, tt.location)
+		}
+	}
+}
+
+func TestRemoveExtraSlash(t *testing.T) {
+	r := newPathTestEngine(func(e *Engine) {
+		e.RemoveExtraSlash = true
+		e.StrictPath = true
+	})
+	if w := r.Perform(httptest.NewRequest("GET", "/v1//hello", nil)); w.Code != 200 {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+}
+
+func TestStrictPath(t *testing.T) {
+	lenient := newPathTestEngine(func(e *Engine) {})
+	strict := newPathTestEngine(func(e *Engine) { e.StrictPath = true })
+	for _, p := range []string{"/v1/hello/", "/v1//hello", "/v1/dir"} {
+		if w := lenient.Perform(httptest.NewRequest("GET", p, nil)); w.Code != 200 {
+			t.Errorf("lenient %s: status %d, want 200", p, w.Code)
+		}
+		if w := strict.Perform(httptest.NewRequest("GET", p, nil)); w.Code != 404 {
+			t.Errorf("strict %s: status %d, want 404", p, w.Code)
+		}
+	}
+}
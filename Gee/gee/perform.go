@@ -0,0 +1,21 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// NewContext creates a Context of the engine outside of ServeHTTP,
+// it lets tests call a handler directly
+func (engine *Engine) NewContext(w http.ResponseWriter, req *http.Request) *Context {
+	c := newContext(w, req)
+	c.engine = engine
+	return c
+}
+
+// Perform serves req through the whole engine and records the response
+func (engine *Engine) Perform(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,356 @@
+package gee
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balancing picks the upstream of a proxied request
+type Balancing int
+
+const (
+	// RoundRobin sends the requests to the upstreams in turn
+	RoundRobin Balancing = iota
+	// LeastConnections sends a request to the upstream with the fewest requests in flight
+	LeastConnections
+	// ConsistentHash sends the requests with the same key to the same upstream,
+	// a change of the upstreams only moves the keys of the changed upstream
+	ConsistentHash
+)
+
+// ProxyOptions configures Proxy, the zero value is round-robin without retries
+type ProxyOptions struct {
+	Balancing Balancing
+	// HashKey is the key of ConsistentHash, nil uses the client IP
+	HashKey func(*Context) string
+	// Rewrite is the upstream path filled with the route params, eg. /v2/users/:id
+	// or /*filepath, empty forwards the request path. A filled path leaving the
+	// static prefix of Rewrite, eg. with .. in a param, gets 400
+	Rewrite string
+	// Retries is how many other upstreams an idempotent request is sent to
+	// after a connection error or a 502, 503 or 504
+	Retries int
+	// MaxFails failures in a row take an upstream out for FailTimeout, default 3 and 10s
+	MaxFails    int
+	FailTimeout time.Duration
+	// PreserveHost sends the Host of the client instead of the one of the upstream
+	PreserveHost bool
+	// Transport sends the upstream requests, nil uses http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// upstream is one target of the proxy with its passive health
+type upstream struct {
+	url    *url.URL
+	active int64 // requests in flight
+
+	mu        sync.Mutex
+	fails     int
+	downUntil time.Time
+}
+
+func (u *upstream) healthy(now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return !now.Before(u.downUntil)
+}
+
+// report counts failures in a row, MaxFails of them take the upstream out
+func (u *upstream) report(ok bool, options *ProxyOptions) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if ok {
+		u.fails = 0
+		return
+	}
+	u.fails++
+	if u.fails >= options.MaxFails {
+		u.fails = 0
+		u.downUntil = time.Now().Add(options.FailTimeout)
+	}
+}
+
+type proxy struct {
+	options   ProxyOptions
+	upstreams []*upstream
+	next      uint64 // round-robin counter
+	ring      []ringPoint
+}
+
+// ringPoint is a virtual node of an upstream on the consistent hash ring
+type ringPoint struct {
+	hash     uint32
+	upstream *upstream
+}
+
+const virtualNodes = 100
+
+// Proxy forwards the requests to the upstreams, eg.
+// api.Handle("GET", "/users/*path", gee.Proxy([]string{"http://10.0.0.1:8080"}, gee.ProxyOptions{Rewrite: "/*path"})).
+// X-Forwarded-For, X-Forwarded-Host and X-Forwarded-Proto tell the upstream about
+// the client, the X-Forwarded-For of a trusted proxy is extended.
+// An upstream failing MaxFails times in a row is skipped for FailTimeout,
+// requests go to the skipped upstreams only when no other is left.
+// Proxy panics on an invalid upstream URL
+func Proxy(upstreams []string, options ProxyOptions) HandlerFunc {
+	if len(upstreams) == 0 {
+		panic("gee: Proxy needs at least one upstream")
+	}
+	if options.MaxFails <= 0 {
+		options.MaxFails = 3
+	}
+	if options.FailTimeout <= 0 {
+		options.FailTimeout = 10 * time.Second
+	}
+	if options.Transport == nil {
+		options.Transport = http.DefaultTransport
+	}
+	if options.HashKey == nil {
+		options.HashKey = (*Context).ClientIP
+	}
+
+	p := &proxy{options: options}
+	for _, raw := range upstreams {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			panic("gee: invalid proxy upstream " + raw)
+		}
+		up := &upstream{url: u}
+		p.upstreams = append(p.upstreams, up)
+		for i := 0; i < virtualNodes; i++ {
+			p.ring = append(p.ring, ringPoint{hash: crc32.ChecksumIEEE([]byte(raw + "#" + strconv.Itoa(i))), upstream: up})
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool { return p.ring[i].hash < p.ring[j].hash })
+	return p.serve
+}
+
+// pick returns a healthy upstream not tried yet, or an unhealthy one if none is left
+func (p *proxy) pick(c *Context, tried map[*upstream]bool) *upstream {
+	now := time.Now()
+	for _, healthyOnly := range []bool{true, false} {
+		usable := func(u *upstream) bool {
+			return !tried[u] && (!healthyOnly || u.healthy(now))
+		}
+		switch p.options.Balancing {
+		case LeastConnections:
+			var best *upstream
+			for _, u := range p.upstreams {
+				if usable(u) && (best == nil || atomic.LoadInt64(&u.active) < atomic.LoadInt64(&best.active)) {
+					best = u
+				}
+			}
+			if best != nil {
+				return best
+			}
+		case ConsistentHash:
+			hash := crc32.ChecksumIEEE([]byte(p.options.HashKey(c)))
+			start := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= hash })
+			for i := 0; i < len(p.ring); i++ {
+				if u := p.ring[(start+i)%len(p.ring)].upstream; usable(u) {
+					return u
+				}
+			}
+		default:
+			start := atomic.AddUint64(&p.next, 1) - 1
+			for i := 0; i < len(p.upstreams); i++ {
+				if u := p.upstreams[(start+uint64(i))%uint64(len(p.upstreams))]; usable(u) {
+					return u
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (p *proxy) serve(c *Context) {
+	attempts := 1
+	var body []byte
+	if p.options.Retries > 0 && isIdempotent(c.Method) {
+		attempts += p.options.Retries
+		if c.Req.Body != nil && c.Req.Body != http.NoBody {
+			// kept for the retries
+			var err error
+			if body, err = io.ReadAll(c.Req.Body); err != nil {
+				if c.bodyError() == nil {
+					c.String(http.StatusBadRequest, "400 BAD REQUEST: %v\n", err)
+				}
+				return
+			}
+		}
+	}
+
+	path, rawPath := c.Req.URL.Path, c.Req.URL.EscapedPath()
+	if p.options.Rewrite != "" {
+		var ok bool
+		if path, ok = fillPattern(p.options.Rewrite, c.Params); !ok {
+			c.String(http.StatusBadRequest, "400 BAD REQUEST: %s leaves the upstream path\n", c.Req.URL.Path)
+			return
+		}
+		rawPath = escapePath(path)
+	}
+
+	tried := make(map[*upstream]bool)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		u := p.pick(c, tried)
+		if u == nil {
+			break
+		}
+		tried[u] = true
+
+		req := p.outgoing(c, u, path, rawPath)
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+		}
+		atomic.AddInt64(&u.active, 1)
+		resp, err := p.options.Transport.RoundTrip(req)
+		failed := err != nil || resp.StatusCode == http.StatusBadGateway ||
+			resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout
+		u.report(!failed, &p.options)
+
+		if failed && attempt < attempts && len(tried) < len(p.upstreams) {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			atomic.AddInt64(&u.active, -1)
+			continue
+		}
+		if err != nil {
+			atomic.AddInt64(&u.active, -1)
+			if c.bodyError() == nil {
+				c.engine.logger.Printf("proxy %s %s: %v", c.Method, u.url.Host, err)
+				c.String(http.StatusBadGateway, "502 BAD GATEWAY\n")
+			}
+			return
+		}
+		p.copyResponse(c, u, resp)
+		resp.Body.Close()
+		atomic.AddInt64(&u.active, -1)
+		return
+	}
+	c.String(http.StatusBadGateway, "502 BAD GATEWAY\n")
+}
+
+// outgoing builds the upstream request from the one of the client,
+// rawPath is the escaped form of path
+func (p *proxy) outgoing(c *Context, u *upstream, path, rawPath string) *http.Request {
+	req := c.Req.Clone(c.Req.Context())
+	req.RequestURI = ""
+	req.URL.Scheme = u.url.Scheme
+	req.URL.Host = u.url.Host
+	req.URL.Path = singleJoiningSlash(u.url.Path, path)
+	req.URL.RawPath = singleJoiningSlash(u.url.EscapedPath(), rawPath)
+	if !p.options.PreserveHost {
+		req.Host = ""
+	}
+	removeHopHeaders(req.Header)
+
+	forwardedFor := c.RemoteIP()
+	if prior := c.headerList("X-Forwarded-For"); prior != "" && c.fromTrustedProxy() {
+		forwardedFor = prior + ", " + forwardedFor
+	}
+	req.Header.Set("X-Forwarded-For", forwardedFor)
+	req.Header.Set("X-Forwarded-Host", c.Host())
+	req.Header.Set("X-Forwarded-Proto", c.Scheme())
+	InjectTrace(c.Req.Context(), req.Header)
+	return req
+}
+
+func (p *proxy) copyResponse(c *Context, u *upstream, resp *http.Response) {
+	removeHopHeaders(resp.Header)
+	header := c.Writer.Header()
+	for name, values := range resp.Header {
+		header[name] = append(header[name], values...)
+	}
+	c.Status(resp.StatusCode)
+	if _, err := io.Copy(c.Writer, resp.Body); err != nil {
+		c.engine.logger.Printf("proxy %s %s: copy response: %v", c.Method, u.url.Host, err)
+	}
+}
+
+// hopHeaders are meant for one connection and not forwarded
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+func removeHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// fillPattern replaces the :param and *wildcard parts of pattern with params,
+// the result is a cleaned, unescaped path. ok is false if it leaves the static
+// prefix of pattern, eg. /v2/users/ of /v2/users/:id
+func fillPattern(pattern string, params map[string]string) (filled string, ok bool) {
+	prefix := pattern
+	if i := strings.IndexAny(pattern, ":*"); i >= 0 {
+		prefix = pattern[:strings.LastIndex(pattern[:i], "/")+1]
+	}
+	segments := strings.Split(pattern, "/")
+	for i, seg := range segments {
+		if seg == "" {
+			continue
+		}
+		switch seg[0] {
+		case ':':
+			name, _ := splitParam(seg)
+			segments[i] = params[name]
+		case '*':
+			segments[i] = params[seg[1:]]
+		}
+	}
+	filled = strings.Join(segments, "/")
+	trailing := strings.HasSuffix(filled, "/")
+	filled = path.Clean(filled)
+	if trailing && filled != "/" {
+		filled += "/"
+	}
+	return filled, filled == strings.TrimSuffix(prefix, "/") || strings.HasPrefix(filled, prefix)
+}
+
+// escapePath escapes every segment of p
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+func singleJoiningSlash(a, b string) string {
+	switch aslash, bslash := strings.HasSuffix(a, "/"), strings.HasPrefix(b, "/"); {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,173 @@
+package gee
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newUpstream answers with its name and the path and headers it got
+func newUpstream(t *testing.T, name string, status int) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		w.Header().Set("X-Upstream", name)
+		w.WriteHeader(status)
+		io.WriteString(w, name+" "+req.URL.Path+" "+req.Header.Get("X-Forwarded-For")+" "+req.Header.Get("X-Forwarded-Host")+" "+string(body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestProxyRoundRobin(t *testing.T) {
+	a, b := newUpstream(t, "a", 200), newUpstream(t, "b", 200)
+	r := New(WithMode(TestMode))
+	r.GET("/api/users/:id", Proxy([]string{a.URL, b.URL + "/base"}, ProxyOptions{Rewrite: "/v2/users/:id"}))
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest("GET", "http://example.com/api/users/42", nil)
+		req.RemoteAddr = "1.2.3.4:5678"
+		got = append(got, r.Perform(req).Body.String())
+	}
+	want := []string{
+		"a /v2/users/42 1.2.3.4 example.com ",
+		"b /base/v2/users/42 1.2.3.4 example.com ",
+	}
+	for i, body := range got {
+		if body != want[i%2] {
+			t.Fatalf("response %d = %q, want %q", i, body, want[i%2])
+		}
+	}
+}
+
+func TestProxyLeastConnections(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "slow")
+	}))
+	defer slow.Close()
+	defer close(release)
+	fast := newUpstream(t, "fast", 200)
+
+	r := New(WithMode(TestMode))
+	r.GET("/", Proxy([]string{slow.URL, fast.URL}, ProxyOptions{Balancing: LeastConnections}))
+	go r.Perform(httptest.NewRequest("GET", "/", nil))
+	<-started // the first request is stuck at slow
+
+	for i := 0; i < 3; i++ {
+		if w := r.Perform(httptest.NewRequest("GET", "/", nil)); w.Header().Get("X-Upstream") != "fast" {
+			t.Fatalf("request %d went to %q", i, w.Header().Get("X-Upstream"))
+		}
+	}
+}
+
+func TestProxyConsistentHash(t *testing.T) {
+	upstreams := []string{newUpstream(t, "a", 200).URL, newUpstream(t, "b", 200).URL, newUpstream(t, "c", 200).URL}
+	r := New(WithMode(TestMode))
+	r.GET("/", Proxy(upstreams, ProxyOptions{
+		Balancing: ConsistentHash,
+		HashKey:   func(c *Context) string { return c.Query("user") },
+	}))
+
+	seen := map[string]bool{}
+	for _, user := range []string{"alice", "bob", "carol", "dave", "erin", "frank"} {
+		first := r.Perform(httptest.NewRequest("GET", "/?user="+user, nil)).Header().Get("X-Upstream")
+		for i := 0; i < 3; i++ {
+			if got := r.Perform(httptest.NewRequest("GET", "/?user="+user, nil)).Header().Get("X-Upstream"); got != first {
+				t.Fatalf("%s went to %s and %s", user, first, got)
+			}
+		}
+		seen[first] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("all keys went to %v", seen)
+	}
+}
+
+func TestProxyRetriesAndHealth(t *testing.T) {
+	down, up := newUpstream(t, "down", 503), newUpstream(t, "up", 200)
+	r := New(WithMode(TestMode))
+	r.Handle("PUT", "/", Proxy([]string{down.URL, up.URL}, ProxyOptions{Retries: 1, MaxFails: 1, FailTimeout: time.Minute}))
+	r.POST("/", Proxy([]string{down.URL, up.URL}, ProxyOptions{Retries: 1}))
+
+	// round-robin starts at down, the PUT is retried with its body at up
+	w := r.Perform(httptest.NewRequest("PUT", "/", strings.NewReader("data")))
+	if w.Code != 200 || !strings.HasSuffix(w.Body.String(), " data") {
+		t.Fatalf("status = %d, body = %q", w.Code, w.Body.String())
+	}
+	// down is out now, so every request goes to up
+	for i := 0; i < 3; i++ {
+		if w := r.Perform(httptest.NewRequest("PUT", "/", nil)); w.Header().Get("X-Upstream") != "up" {
+			t.Fatalf("request %d went to %q", i, w.Header().Get("X-Upstream"))
+		}
+	}
+
+	// a POST is not retried, the answer of down is passed on
+	if w := r.Perform(httptest.NewRequest("POST", "/", strings.NewReader("order"))); w.Code != 503 {
+		t.Fatalf("POST status = %d", w.Code)
+	}
+}
+
+func TestProxyUnreachable(t *testing.T) {
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+	r := New(WithMode(TestMode))
+	r.GET("/*path", Proxy([]string{gone.URL}, ProxyOptions{Rewrite: "/*path"}))
+	if w := r.Perform(httptest.NewRequest("GET", "/a/b", nil)); w.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d", w.Code)
+	}
+}
+
+func TestProxyPath(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, req.URL.EscapedPath())
+	}))
+	defer upstream.Close()
+	r := New(WithMode(TestMode))
+	r.GET("/files/*path", Proxy([]string{upstream.URL}, ProxyOptions{Rewrite: "/static/*path"}))
+	r.GET("/raw/*path", Proxy([]string{upstream.URL + "/base"}, ProxyOptions{}))
+
+	tests := map[string]string{
+		"/files/css/a%20b.css":    "/static/css/a%20b.css",
+		"/files/a/../b":           "/static/b",
+		"/raw/a%2Fb/c%3F":         "/base/raw/a%2Fb/c%3F",
+		"/files/../../etc/passwd": "",
+	}
+	for target, want := range tests {
+		w := r.Perform(httptest.NewRequest("GET", target, nil))
+		if want == "" {
+			if w.Code != 400 {
+				t.Errorf("%s: status = %d, want 400", target, w.Code)
+			}
+			continue
+		}
+		if w.Code != 200 || w.Body.String() != want {
+			t.Errorf("%s: %d %q, want %q", target, w.Code, w.Body.String(), want)
+		}
+	}
+}
+
+func TestFillPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		params  map[string]string
+		want    string
+		ok      bool
+	}{
+		{"/v2/users/:id", map[string]string{"id": "42"}, "/v2/users/42", true},
+		{"/v2/users/:id/", map[string]string{"id": "42"}, "/v2/users/42/", true},
+		{"/v2/users/:id", map[string]string{"id": ".."}, "/v2", false},
+		{"/v2/users/:id", map[string]string{"id": ""}, "/v2/users/", true},
+		{"/*path", map[string]string{"path": "../../x"}, "/x", true},
+	}
+	for _, tt := range tests {
+		if got, ok := fillPattern(tt.pattern, tt.params); got != tt.want || ok != tt.ok {
+			t.Errorf("fillPattern(%q, %v) = %q, %v, want %q, %v", tt.pattern, tt.params, got, ok, tt.want, tt.ok)
+		}
+	}
+}
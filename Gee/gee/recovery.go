@@ -2,7 +2,6 @@ package gee
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"runtime"
 	"strings"
@@ -34,7 +33,7 @@ func Recovery() HandlerFunc{
 		defer func(){
 			if err := recover(); err != nil{
 				message := fmt.Sprintf("%s", err)
-				log.Printf("%s\n\n", trace(message))
+				c.engine.logger.Printf("%s\n\n", trace(message))
 				c.Status(http.StatusInternalServerError)
 			}
 		}()
@@ -0,0 +1,38 @@
+package gee
+
+// HeaderRequestID is the header carrying the request id
+const HeaderRequestID = "X-Request-ID"
+
+const requestIDKey = "gee.requestID"
+
+// RequestID accepts the X-Request-ID of the request or generates a new one,
+// stores it on the Context and echoes it in the response
+func RequestID() HandlerFunc {
+	return func(c *Context) {
+		id := c.Req.Header.Get(HeaderRequestID)
+		if !validRequestID(id) {
+			id = randomHex(16)
+		}
+		c.Set(requestIDKey, id)
+		c.SetHeader(HeaderRequestID, id)
+		c.Next()
+	}
+}
+
+// RequestID returns the id set by the RequestID middleware
+func (c *Context) RequestID() string {
+	return c.GetString(requestIDKey)
+}
+
+// validRequestID keeps ids from the client short and printable, so they are safe to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package gee
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// responseWriter remembers the status and the size of the response,
+// which middlewares need after the handler has written it
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w}
+}
+
+// WriteHeader only lets the first status through, like net/http does
+func (w *responseWriter) WriteHeader(code int) {
+	if w.Written() {
+		return
+	}
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if !w.Written() {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+// Status returns the written status, 0 if nothing was written yet
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+// Size returns the number of body bytes written
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+// Written reports whether the header was sent
+func (w *responseWriter) Written() bool {
+	return w.status != 0
+}
+
+func (w *responseWriter) Flush() {
+	if !w.Written() {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("gee: the ResponseWriter does not implement http.Hijacker")
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the original ResponseWriter
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
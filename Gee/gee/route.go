@@ -0,0 +1,138 @@
+package gee
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Route is returned when a route is registered, it is used to name the route
+// and to describe it for the OpenAPI document
+type Route struct {
+	Method  string
+	Pattern string // full pattern including the group prefix
+
+	handlers []HandlerFunc
+	engine   *Engine
+	host     *hostRoute
+
+	name        string
+	summary     string
+	description string
+	tags        []string
+	request     reflect.Type
+	responses   map[int]reflect.Type
+	hidden      bool // left out of the OpenAPI document
+}
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	Method      string
+	Host        string // host pattern, empty for the default route tree
+	Path        string
+	Name        string
+	Handler     string // name of the handler function
+	Middlewares int    // group middlewares and route middlewares in front of the handler
+}
+
+// Name registers the route under name, so its URL can be built by Engine.URL
+func (r *Route) Name(name string) *Route {
+	r.engine.mu.Lock()
+	defer r.engine.mu.Unlock()
+	if old, ok := r.engine.namedRoutes[name]; ok && old != r {
+		panic(fmt.Sprintf("gee: route name %q is already used by %s", name, old.Pattern))
+	}
+	r.engine.namedRoutes[name] = r
+	r.name = name
+	return r
+}
+
+// Summary sets the one line summary of the operation
+func (r *Route) Summary(summary string) *Route {
+	r.summary = summary
+	return r
+}
+
+// Description sets the long description of the operation
+func (r *Route) Description(description string) *Route {
+	r.description = description
+	return r
+}
+
+// Tags groups the operation in the OpenAPI document
+func (r *Route) Tags(tags ...string) *Route {
+	r.tags = append(r.tags, tags...)
+	return r
+}
+
+// Request sets the JSON request body type, v is a value of that type eg. User{}
+func (r *Route) Request(v interface{}) *Route {
+	r.request = reflect.TypeOf(v)
+	return r
+}
+
+// Response sets the JSON body type returned with the status code, v may be nil for an empty body
+func (r *Route) Response(code int, v interface{}) *Route {
+	if r.responses == nil {
+		r.responses = make(map[int]reflect.Type)
+	}
+	r.responses[code] = reflect.TypeOf(v)
+	return r
+}
+
+// Routes returns all registered routes in registration order
+func (engine *Engine) Routes() []RouteInfo {
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+	infos := make([]RouteInfo, 0, len(engine.routes))
+	for _, route := range engine.routes {
+		middlewares := len(route.handlers) - 1
+		for _, group := range engine.groups {
+			if (group.host == nil || group.host == route.host) && strings.HasPrefix(route.Pattern, group.prefix) {
+				middlewares += len(group.middlewares)
+			}
+		}
+		info := RouteInfo{
+			Method:      route.Method,
+			Path:        route.Pattern,
+			Name:        route.name,
+			Handler:     nameOfFunction(route.handlers[len(route.handlers)-1]),
+			Middlewares: middlewares,
+		}
+		if route.host != nil {
+			info.Host = route.host.pattern
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// RemoveRoute removes the route of method and pattern registered by the group,
+// eg. r.RemoveRoute("GET", "/v1/hello/:name") or r.Host("api.example.com").RemoveRoute("GET", "/a").
+// The pattern is relative to the group, routes of other hosts are left alone.
+// It is safe while serving, requests being served finish with the route.
+// It reports whether the route existed
+func (group *RouterGroup) RemoveRoute(method string, pattern string) bool {
+	engine := group.engine
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	method = strings.ToUpper(method)
+	pattern = group.prefix + pattern
+	for i, route := range engine.routes {
+		if route.Method != method || route.Pattern != pattern || route.host != group.host {
+			continue
+		}
+		group.router().removeRoute(method, pattern)
+		engine.routes = append(engine.routes[:i:i], engine.routes[i+1:]...)
+		if route.name != "" && engine.namedRoutes[route.name] == route {
+			delete(engine.namedRoutes, route.name)
+		}
+		return true
+	}
+	return false
+}
+
+func nameOfFunction(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
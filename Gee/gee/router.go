@@ -1,23 +1,70 @@
 package gee
 
 import (
-	"log"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
-// roots key eg, roots['GET'], roots['POST']
-// handlers key eg, handlers['GET-/p/:lang/doc']
+// router serves requests from a routeTree snapshot. Until the first request the
+// tree is changed in place, afterwards a change copies the nodes on the path of
+// the route and swaps the snapshot, so routes can be added and removed while
+// serving and requests never take a lock
 type router struct {
-	roots    map[string]*node
-	handlers map[string]HandlerFunc
+	mu      sync.Mutex   // serializes the writers
+	tree    atomic.Value // *routeTree
+	serving int32        // set by the first request, from then on the tree is copied
+}
+
+// roots key eg, roots['GET'], roots['POST']
+type routeTree struct {
+	roots  map[string]*node
+	mounts []*mount // engines mounted into this tree
 }
 
 func newRouter() *router {
-	return &router{
-		roots:    make(map[string]*node),
-		handlers: make(map[string]HandlerFunc),
+	r := &router{}
+	r.tree.Store(&routeTree{roots: make(map[string]*node)})
+	return r
+}
+
+// load returns the current tree, it must not be modified
+func (r *router) load() *routeTree {
+	return r.tree.Load().(*routeTree)
+}
+
+// snapshot is load for serving a request, it ends the in-place changes of the setup
+func (r *router) snapshot() *routeTree {
+	if atomic.LoadInt32(&r.serving) == 0 {
+		// waits for a writer still changing the tree in place
+		r.mu.Lock()
+		atomic.StoreInt32(&r.serving, 1)
+		r.mu.Unlock()
 	}
+	return r.load()
+}
+
+// update lets change modify the tree in place during the setup, or a shallow
+// copy of it while serving, inPlace tells change which one it got
+func (r *router) update(change func(t *routeTree, inPlace bool)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.load()
+	if atomic.LoadInt32(&r.serving) == 0 {
+		change(t, true)
+		return
+	}
+	c := &routeTree{
+		roots:  make(map[string]*node, len(t.roots)),
+		mounts: append([]*mount(nil), t.mounts...),
+	}
+	for method, root := range t.roots {
+		c.roots[method] = root
+	}
+	change(c, false)
+	r.tree.Store(c)
 }
 
 // Only one * is allowed
@@ -38,44 +85,79 @@ func parsePattern(pattern string) []string {
 	return parts
 }
 
-func (r *router) addRoute(method string, pattern string, handler HandlerFunc) {
-	log.Printf("Route %4s - %s", method, pattern)
-
+// addRoute registers handlers, the route middlewares followed by the handler
+func (r *router) addRoute(method string, pattern string, handlers []HandlerFunc) {
 	parts := parsePattern(pattern)
-	key := method + "-" + pattern
+	r.update(func(t *routeTree, inPlace bool) {
+		root, ok := t.roots[method]
+		if !ok {
+			root = &node{}
+		}
+		if inPlace {
+			root.insert(pattern, parts, 0, handlers)
+		} else {
+			root = root.insertCopy(pattern, parts, 0, handlers)
+		}
+		t.roots[method] = root
+	})
+}
 
-	_, ok := r.roots[method]
-	if !ok {
-		r.roots[method] = &node{}
-	}
-	r.roots[method].insert(pattern, parts, 0)
-	r.handlers[key] = handler
+// removeRoute removes the route registered for exactly method and pattern,
+// it reports whether there was one
+func (r *router) removeRoute(method string, pattern string) bool {
+	parts := parsePattern(pattern)
+	removed := false
+	r.update(func(t *routeTree, inPlace bool) {
+		root, ok := t.roots[method]
+		if !ok {
+			return
+		}
+		// removing is rare, it copies the path in both cases
+		if root, removed = root.removeCopy(pattern, parts, 0); !removed {
+			return
+		}
+		if root == nil {
+			delete(t.roots, method)
+		} else {
+			t.roots[method] = root
+		}
+	})
+	return removed
 }
 
+// getRoute looks up the current tree, see routeTree.getRoute
+func (r *router) getRoute(method string, path string) (*node, map[string]string) {
+	return r.load().getRoute(method, path)
+}
 
 // getRouter is used to get the matched trie tree node and get the mapping of params->pattern
-func (r *router) getRoute(method string, path string) (*node, map[string]string) {
+func (t *routeTree) getRoute(method string, path string) (*node, map[string]string) {
+	return t.findRoute(method, path, false)
+}
+
+// findRoute is getRoute, with fold the static parts are matched case-insensitively
+func (t *routeTree) findRoute(method string, path string, fold bool) (*node, map[string]string) {
 
 	// parse the input url
 	searchParts := parsePattern(path)
 	params := make(map[string]string)
-	root, ok := r.roots[method]
+	root, ok := t.roots[method]
 
 	if !ok {
 		return nil, nil
 	}
 
-	n := root.search(searchParts, 0)
+	n := root.search(searchParts, 0, fold)
 
 	if n != nil {
 
-
 		parts := parsePattern(n.pattern)
 
 		// matching the dynamic router
 		for index, part := range parts {
 			if part[0] == ':' {
-				params[part[1:]] = searchParts[index]
+				name, _ := splitParam(part)
+				params[name] = searchParts[index]
 			}
 			if part[0] == '*' {
 				params[part[1:]] = strings.Join(searchParts[index:], "/")
@@ -87,23 +169,85 @@ func (r *router) getRoute(method string, path string) (*node, map[string]string)
 	return nil, nil
 }
 
+// allowedMethods returns the sorted methods which have a route matching path,
+// with strict only routes for which path is canonical count
+func (t *routeTree) allowedMethods(path string, strict bool) []string {
+	var methods []string
+	for method := range t.roots {
+		if n, _ := t.getRoute(method, path); n != nil && (!strict || canonicalPath(n.pattern, path) == path) {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func notFound(c *Context) {
+	c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
+}
+
+func methodNotAllowed(c *Context) {
+	c.String(http.StatusMethodNotAllowed, "405 METHOD NOT ALLOWED: %s %s\n", c.Method, c.Path)
+}
+
 func (r *router) handle(c *Context) {
+	engine := c.engine
+	if engine.RemoveExtraSlash {
+		c.Path = removeExtraSlash(c.Path)
+	}
+
+	// one snapshot serves the whole request, even if the routes change meanwhile
+	t := r.snapshot()
+
 	// firstly, get the trie tree node and params mapping
-	n, params := r.getRoute(c.Method, c.Path)
+	n, params := t.getRoute(c.Method, c.Path)
+
+	// parsePattern ignores empty parts, so /v1//hello/ matches /v1/hello as well
+	if n != nil {
+		if canonical := canonicalPath(n.pattern, c.Path); canonical != c.Path {
+			trailing := onlyTrailingSlash(canonical, c.Path)
+			if trailing && engine.RedirectTrailingSlash || !trailing && engine.RedirectFixedPath {
+				c.handlers = append(c.handlers, redirect(canonical))
+				c.Next()
+				return
+			}
+			if engine.StrictPath {
+				n = nil
+			}
+		}
+	}
+	if n == nil && engine.RedirectFixedPath {
+		if fixed, ok := t.fixedPath(c.Method, c.Path); ok && (engine.RedirectTrailingSlash || !onlyTrailingSlash(fixed, c.Path)) {
+			c.handlers = append(c.handlers, redirect(fixed))
+			c.Next()
+			return
+		}
+	}
+
 	// if the pattern exists
 	if n != nil {
-		key := c.Method + "-" + n.pattern
-		c.Params = params
-		// add the pattern process function into handler queue
-		c.handlers = append(c.handlers, r.handlers[key])
+		if c.Params == nil {
+			c.Params = params
+		} else {
+			// keep the host params
+			for key, value := range params {
+				c.Params[key] = value
+			}
+		}
+		c.fullPath = n.pattern
+		// add the pattern process function into handler queue,
+		// the body size is checked once the route middlewares have run
+		last := len(n.handlers) - 1
+		c.handlers = append(c.handlers, n.handlers[:last]...)
+		c.handlers = append(c.handlers, checkContentLength, n.handlers[last])
+	} else if allowed := t.allowedMethods(c.Path, engine.StrictPath); len(allowed) > 0 {
+		// the path exists, but not for this method
+		c.SetHeader("Allow", strings.Join(allowed, ", "))
+		c.handlers = append(c.handlers, t.unmatched(engine, c.Path, true)...)
 	} else {
-		c.handlers = append(c.handlers, func(c *Context) {
-			c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
-		})
+		c.handlers = append(c.handlers, t.unmatched(engine, c.Path, false)...)
 	}
 	// if we have added middleware to this pattern, calling c.Next() will firstly use
 	// middleware function to process and then use the pattern method
 	c.Next()
 }
-
-
@@ -0,0 +1,348 @@
+package gee
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func newTestRouter() *router {
+	r := newRouter()
+	nop := []HandlerFunc{func(c *Context) {}}
+	r.addRoute("GET", "/", nop)
+	r.addRoute("GET", "/hello/:name", nop)
+	r.addRoute("GET", "/hello/b/c", nop)
+	r.addRoute("GET", "/hi/:name", nop)
+	r.addRoute("GET", "/assets/*filepath", nop)
+	return r
+}
+
+func TestParsePattern(t *testing.T) {
+	ok := reflect.DeepEqual(parsePattern("/p/:name"), []string{"p", ":name"})
+	ok = ok && reflect.DeepEqual(parsePattern("/p/*"), []string{"p", "*"})
+	ok = ok && reflect.DeepEqual(parsePattern("/p/*name/*"), []string{"p", "*name"})
+	if !ok {
+		t.Fatal("test parsePattern failed")
+	}
+}
+
+func TestGetRoute(t *testing.T) {
+	r := newTestRouter()
+	n, ps := r.getRoute("GET", "/hello/geektutu")
+	if n == nil {
+		t.Fatal("nil shouldn't be returned")
+	}
+	if n.pattern != "/hello/:name" {
+		t.Fatal("should match /hello/:name")
+	}
+	if ps["name"] != "geektutu" {
+		t.Fatal("name should be equal to 'geektutu'")
+	}
+
+	if n, _ := r.getRoute("GET", "/hello/b/c"); n == nil || n.pattern != "/hello/b/c" {
+		t.Fatal("static route /hello/b/c should match")
+	}
+	if n, _ := r.getRoute("POST", "/hello/geektutu"); n != nil {
+		t.Fatal("POST has no routes")
+	}
+	if n, _ := r.getRoute("GET", "/nothing/here"); n != nil {
+		t.Fatal("nothing should match /nothing/here")
+	}
+}
+
+func TestGetRouteWildcard(t *testing.T) {
+	r := newTestRouter()
+	n, ps := r.getRoute("GET", "/assets/css/geektutu.css")
+	if n == nil || n.pattern != "/assets/*filepath" {
+		t.Fatal("should match /assets/*filepath")
+	}
+	if ps["filepath"] != "css/geektutu.css" {
+		t.Fatalf("filepath = %q, want css/geektutu.css", ps["filepath"])
+	}
+}
+
+func TestHandleNotFound(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) {})
+	w := r.Perform(httptest.NewRequest("GET", "/missing", nil))
+	if w.Code != 404 || w.Body.String() != "404 NOT FOUND: /missing\n" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleMethodNotAllowed(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", func(c *Context) {})
+	r.POST("/users/:id", func(c *Context) {})
+	req := httptest.NewRequest("DELETE", "/users/1", nil)
+	w := r.Perform(req)
+	if w.Code != 405 || w.Header().Get("Allow") != "GET, POST" {
+		t.Fatalf("got %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestCustomNoRouteAndNoMethod(t *testing.T) {
+	r := New()
+	var logged []string
+	r.Use(func(c *Context) {
+		c.Next()
+		logged = append(logged, c.Path)
+	})
+	r.NoRoute(func(c *Context) {
+		c.JSON(404, H{"error": "no route", "path": c.Path})
+	})
+	r.NoMethod(func(c *Context) {
+		c.JSON(405, H{"error": "no method", "allow": c.Writer.Header().Get("Allow")})
+	})
+	r.GET("/", func(c *Context) {})
+
+	w := r.Perform(httptest.NewRequest("GET", "/missing", nil))
+	if w.Code != 404 || w.Body.String() != "{\"error\":\"no route\",\"path\":\"/missing\"}\n" {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+	w = r.Perform(httptest.NewRequest("POST", "/", nil))
+	if w.Code != 405 || w.Body.String() != "{\"allow\":\"GET\",\"error\":\"no method\"}\n" {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+	if len(logged) != 2 {
+		t.Fatalf("global middleware ran for %v", logged)
+	}
+}
+
+func TestConstrainedParams(t *testing.T) {
+	r := New()
+	r.GET("/items/:id<int>", func(c *Context) {
+		id, err := c.ParamInt("id")
+		c.String(200, "int %d %v", id, err)
+	})
+	r.GET("/items/:uuid<uuid>", func(c *Context) {
+		c.String(200, "uuid %s", c.Param("uuid"))
+	})
+	r.GET("/items/:slug<[a-z0-9-]+>", func(c *Context) {
+		c.String(200, "slug %s", c.Param("slug"))
+	})
+	r.GET("/items/new", func(c *Context) {
+		c.String(200, "static")
+	})
+
+	tests := map[string]string{
+		"/items/42": "int 42 <nil>",
+		"/items/6ba7b810-9dad-11d1-80b4-00c04fd430c8": "uuid 6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"/items/hello-world":                          "slug hello-world",
+		"/items/new":                                  "static",
+		"/items/Hello":                                "404 NOT FOUND: /items/Hello\n",
+	}
+	for path, want := range tests {
+		if got := r.Perform(httptest.NewRequest("GET", path, nil)).Body.String(); got != want {
+			t.Errorf("%s: got %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestStaticPartNotMatchedByParamSibling(t *testing.T) {
+	r := newTestRouter()
+	if n, _ := r.getRoute("GET", "/hello/x/c"); n != nil {
+		t.Fatalf("/hello/x/c matched %s", n.pattern)
+	}
+}
+
+func TestURLConstraint(t *testing.T) {
+	r := New()
+	r.GET("/items/:id<int>", func(c *Context) {}).Name("item")
+	if got, err := r.URL("item", 7); err != nil || got != "/items/7" {
+		t.Fatalf("URL = %q, %v", got, err)
+	}
+	_, err := r.URL("item", "abc")
+	if err == nil || err.Error() != `gee: route "item": "abc" does not match :id<int>` {
+		t.Fatalf("abc should not satisfy <int>, err = %v", err)
+	}
+}
+
+func TestRemoveRoute(t *testing.T) {
+	r := New(WithMode(TestMode))
+	r.GET("/plugins/:name", func(c *Context) {
+		c.String(200, "plugin %s", c.Param("name"))
+	}).Name("plugin")
+	r.GET("/plugins/:name/status", func(c *Context) {
+		c.String(200, "ok")
+	})
+
+	if !r.RemoveRoute("GET", "/plugins/:name") {
+		t.Fatal("RemoveRoute should find the route")
+	}
+	if r.RemoveRoute("GET", "/plugins/:name") {
+		t.Fatal("the route was removed already")
+	}
+	if w := r.Perform(httptest.NewRequest("GET", "/plugins/a", nil)); w.Code != 404 {
+		t.Fatalf("status = %d after removing the route", w.Code)
+	}
+	if w := r.Perform(httptest.NewRequest("GET", "/plugins/a/status", nil)); w.Code != 200 {
+		t.Fatalf("the longer route is gone, status = %d", w.Code)
+	}
+	if _, err := r.URL("plugin", "a"); err == nil {
+		t.Fatal("the name of a removed route should be gone")
+	}
+	if len(r.Routes()) != 1 {
+		t.Fatalf("routes = %v", r.Routes())
+	}
+}
+
+func TestRemoveRouteHost(t *testing.T) {
+	r := New(WithMode(TestMode))
+	r.GET("/a", func(c *Context) {
+		c.String(200, "default")
+	})
+	api := r.Host("api.example.com")
+	api.GET("/a", func(c *Context) {
+		c.String(200, "api")
+	})
+	get := func(host string) string {
+		req := httptest.NewRequest("GET", "http://"+host+"/a", nil)
+		w := r.Perform(req)
+		return fmt.Sprintf("%d %s", w.Code, w.Body.String())
+	}
+
+	if !api.RemoveRoute("GET", "/a") {
+		t.Fatal("the route of the host should be found")
+	}
+	if got := get("api.example.com"); got != "404 404 NOT FOUND: /a\n" {
+		t.Fatalf("api host after removing its route: %q", got)
+	}
+	if got := get("example.com"); got != "200 default" {
+		t.Fatalf("default tree: %s", got)
+	}
+	if !r.RemoveRoute("GET", "/a") || r.RemoveRoute("GET", "/a") {
+		t.Fatal("the default route should be removed once")
+	}
+	if got := get("example.com"); got != "404 404 NOT FOUND: /a\n" {
+		t.Fatalf("default tree after removing its route: %q", got)
+	}
+	if len(r.Routes()) != 0 {
+		t.Fatalf("routes = %v", r.Routes())
+	}
+}
+
+func TestRouteRegisteredAgain(t *testing.T) {
+	r := New(WithMode(TestMode))
+	r.GET("/plugins/:name", func(c *Context) {
+		c.String(200, "v1")
+	}).Name("plugin")
+	r.GET("/plugins/:name", func(c *Context) {
+		c.String(200, "v2")
+	})
+	if len(r.Routes()) != 1 {
+		t.Fatalf("routes = %v", r.Routes())
+	}
+	if w := r.Perform(httptest.NewRequest("GET", "/plugins/a", nil)); w.Body.String() != "v2" {
+		t.Fatalf("body = %q", w.Body.String())
+	}
+	if url, err := r.URL("plugin", "a"); err != nil || url != "/plugins/a" {
+		t.Fatalf("URL = %q, %v", url, err)
+	}
+
+	r.RemoveRoute("GET", "/plugins/:name")
+	if len(r.Routes()) != 0 {
+		t.Fatalf("routes = %v after RemoveRoute", r.Routes())
+	}
+	if _, err := r.URL("plugin", "a"); err == nil {
+		t.Fatal("the name of a removed route should be gone")
+	}
+}
+
+// run with -race: routes and groups change while requests are served
+func TestRoutesChangeWhileServing(t *testing.T) {
+	r := New(WithMode(TestMode))
+	r.GET("/", func(c *Context) {
+		c.String(200, "home")
+	})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if w := r.Perform(httptest.NewRequest("GET", "/", nil)); w.Code != 200 {
+					t.Errorf("status = %d", w.Code)
+					return
+				}
+				r.Perform(httptest.NewRequest("GET", "/plugins/3/run", nil))
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		plugin := r.Group(fmt.Sprintf("/plugins/%d", i))
+		plugin.Use(func(c *Context) {})
+		plugin.GET("/run", func(c *Context) {
+			c.String(200, "run")
+		})
+		if i%2 == 1 {
+			r.RemoveRoute("GET", fmt.Sprintf("/plugins/%d/run", i-1))
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if w := r.Perform(httptest.NewRequest("GET", "/plugins/49/run", nil)); w.Body.String() != "run" {
+		t.Fatalf("body = %q", w.Body.String())
+	}
+	if w := r.Perform(httptest.NewRequest("GET", "/plugins/48/run", nil)); w.Code != 404 {
+		t.Fatalf("status = %d for a removed route", w.Code)
+	}
+}
+
+func benchmarkAddRoutes(b *testing.B, serving bool) {
+	patterns := make([]string, 5000)
+	for i := range patterns {
+		patterns[i] = fmt.Sprintf("/api/v%d/resource%d/:id/items", i%10, i)
+	}
+	handler := func(c *Context) {}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := New(WithMode(TestMode))
+		if serving {
+			r.Perform(httptest.NewRequest("GET", "/", nil))
+		}
+		for _, pattern := range patterns {
+			r.GET(pattern, handler)
+		}
+	}
+}
+
+// registering must stay linear in the number of routes, in the setup and while serving
+func BenchmarkAddRoutes(b *testing.B) {
+	benchmarkAddRoutes(b, false)
+}
+
+func BenchmarkAddRoutesWhileServing(b *testing.B) {
+	benchmarkAddRoutes(b, true)
+}
+
+func TestRouteSnapshotUnchanged(t *testing.T) {
+	r := newTestRouter()
+	before := r.snapshot()
+	r.addRoute("GET", "/hello/:name/more", []HandlerFunc{func(c *Context) {}})
+	r.removeRoute("GET", "/hi/:name")
+
+	if n, _ := before.getRoute("GET", "/hello/a/more"); n != nil {
+		t.Fatal("a route added later shows up in an older snapshot")
+	}
+	if n, _ := before.getRoute("GET", "/hi/a"); n == nil {
+		t.Fatal("a route removed later is gone from an older snapshot")
+	}
+	if n, _ := r.getRoute("GET", "/hello/a/more"); n == nil {
+		t.Fatal("the added route is missing")
+	}
+	if n, _ := r.getRoute("GET", "/hi/a"); n != nil {
+		t.Fatal("the removed route is still there")
+	}
+}
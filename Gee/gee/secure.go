@@ -0,0 +1,160 @@
+package gee
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SecureConfig configures the Secure middleware, empty fields add no header
+type SecureConfig struct {
+	// STSSeconds is the max-age of Strict-Transport-Security, sent on https requests only
+	STSSeconds           int64
+	STSIncludeSubdomains bool
+	STSPreload           bool
+	// ContentTypeNosniff sets X-Content-Type-Options: nosniff
+	ContentTypeNosniff bool
+	// FrameOptions is the X-Frame-Options value, eg. DENY or SAMEORIGIN
+	FrameOptions string
+	// ReferrerPolicy is the Referrer-Policy value, eg. strict-origin-when-cross-origin
+	ReferrerPolicy string
+	// ContentSecurityPolicy is the Content-Security-Policy value, every {nonce} in it
+	// is replaced by a new nonce per request, eg. script-src 'self' 'nonce-{nonce}'
+	ContentSecurityPolicy string
+	// SSLRedirect redirects http requests to https
+	SSLRedirect bool
+	// SSLHost is the host of the https redirect, empty means the host of the request
+	SSLHost string
+	// AllowedHosts rejects requests for other hosts with 400, empty allows all hosts
+	AllowedHosts []string
+}
+
+// DefaultSecureConfig returns a strict configuration for HTML applications served over https
+func DefaultSecureConfig() SecureConfig {
+	return SecureConfig{
+		STSSeconds:            31536000,
+		STSIncludeSubdomains:  true,
+		ContentTypeNosniff:    true,
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-{nonce}'; object-src 'none'; base-uri 'self'",
+		SSLRedirect:           true,
+	}
+}
+
+const cspNonceKey = "gee.cspNonce"
+
+// Secure adds the hardening headers to every response. The CSP nonce of the
+// request is given by Context.CSPNonce and passed to templates as .CSPNonce
+func Secure(config SecureConfig) HandlerFunc {
+	sts := ""
+	if config.STSSeconds > 0 {
+		sts = "max-age=" + strconv.FormatInt(config.STSSeconds, 10)
+		if config.STSIncludeSubdomains {
+			sts += "; includeSubDomains"
+		}
+		if config.STSPreload {
+			sts += "; preload"
+		}
+	}
+	allowedHosts := make(map[string]bool, len(config.AllowedHosts))
+	for _, host := range config.AllowedHosts {
+		allowedHosts[strings.ToLower(host)] = true
+	}
+
+	return func(c *Context) {
+		if len(allowedHosts) > 0 && !allowedHosts[hostname(c.Host())] {
+			c.String(http.StatusBadRequest, "400 BAD HOST: %s\n", c.Host())
+			c.Abort()
+			return
+		}
+
+		https := c.Scheme() == "https"
+		if config.SSLRedirect && !https {
+			host := config.SSLHost
+			if host == "" {
+				host = c.Host()
+			}
+			code := http.StatusPermanentRedirect
+			if c.Method == http.MethodGet || c.Method == http.MethodHead {
+				code = http.StatusMovedPermanently
+			}
+			c.Redirect(code, "https://"+host+c.Req.URL.RequestURI())
+			c.Abort()
+			return
+		}
+
+		header := c.Writer.Header()
+		if sts != "" && https {
+			header.Set("Strict-Transport-Security", sts)
+		}
+		if config.ContentTypeNosniff {
+			header.Set("X-Content-Type-Options", "nosniff")
+		}
+		if config.FrameOptions != "" {
+			header.Set("X-Frame-Options", config.FrameOptions)
+		}
+		if config.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", config.ReferrerPolicy)
+		}
+		if csp := config.ContentSecurityPolicy; csp != "" {
+			if strings.Contains(csp, "{nonce}") {
+				nonce := newNonce()
+				c.Set(cspNonceKey, nonce)
+				csp = strings.Replace(csp, "{nonce}", nonce, -1)
+			}
+			header.Set("Content-Security-Policy", csp)
+		}
+		c.Next()
+	}
+}
+
+// CSPNonce returns the nonce of the Content-Security-Policy of this request,
+// it goes into <script nonce="..."> tags
+func (c *Context) CSPNonce() string {
+	return c.GetString(cspNonceKey)
+}
+
+// withCSPNonce adds the nonce as .CSPNonce to template data given as H or nil,
+// other data types have to carry c.CSPNonce() themselves
+func (c *Context) withCSPNonce(data interface{}) interface{} {
+	nonce := c.CSPNonce()
+	if nonce == "" {
+		return data
+	}
+	var m map[string]interface{}
+	switch d := data.(type) {
+	case nil:
+	case H:
+		m = d
+	case map[string]interface{}:
+		m = d
+	default:
+		return data
+	}
+	out := make(H, len(m)+1)
+	for key, value := range m {
+		out[key] = value
+	}
+	if _, ok := out["CSPNonce"]; !ok {
+		out["CSPNonce"] = nonce
+	}
+	return out
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hostname strips the port and lower cases host
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
@@ -0,0 +1,79 @@
+package gee
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	config := DefaultSecureConfig()
+	config.SSLRedirect = false
+	config.STSPreload = true
+	r := New()
+	r.Use(Secure(config))
+	r.LoadHTMLFS(fstest.MapFS{
+		"page.tmpl": {Data: []byte(`<script nonce="{{.CSPNonce}}"></script><p>{{.msg}}</p>`)},
+	}, "*.tmpl")
+	r.GET("/", func(c *Context) {
+		c.HTML(200, "page.tmpl", H{"msg": "hi"})
+	})
+
+	req := httptest.NewRequest("GET", "https://example.com/", nil)
+	w := r.Perform(req)
+	h := w.Header()
+	if h.Get("Strict-Transport-Security") != "max-age=31536000; includeSubDomains; preload" ||
+		h.Get("X-Content-Type-Options") != "nosniff" || h.Get("X-Frame-Options") != "DENY" ||
+		h.Get("Referrer-Policy") != "strict-origin-when-cross-origin" {
+		t.Fatalf("unexpected headers %v", h)
+	}
+
+	csp := h.Get("Content-Security-Policy")
+	i := strings.Index(csp, "'nonce-")
+	if i < 0 {
+		t.Fatalf("no nonce in %q", csp)
+	}
+	nonce := csp[i+len("'nonce-"):]
+	nonce = nonce[:strings.IndexByte(nonce, '\'')]
+	if w.Body.String() != `<script nonce="`+nonce+`"></script><p>hi</p>` {
+		t.Fatalf("body = %q, nonce %q", w.Body.String(), nonce)
+	}
+
+	// no HSTS over plain http, and a new nonce per request
+	w2 := r.Perform(httptest.NewRequest("GET", "http://example.com/", nil))
+	if w2.Header().Get("Strict-Transport-Security") != "" || w2.Header().Get("Content-Security-Policy") == csp {
+		t.Fatalf("unexpected headers %v", w2.Header())
+	}
+}
+
+func TestSecureSSLRedirect(t *testing.T) {
+	r := New()
+	r.Use(Secure(SecureConfig{SSLRedirect: true}))
+	r.GET("/a", func(c *Context) {})
+	r.POST("/a", func(c *Context) {})
+
+	w := r.Perform(httptest.NewRequest("GET", "http://example.com/a?x=1", nil))
+	if w.Code != 301 || w.Header().Get("Location") != "https://example.com/a?x=1" {
+		t.Fatalf("got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := r.Perform(httptest.NewRequest("POST", "http://example.com/a", nil)); w.Code != 308 {
+		t.Fatalf("POST status = %d, want 308", w.Code)
+	}
+	if w := r.Perform(httptest.NewRequest("GET", "https://example.com/a", nil)); w.Code != 200 {
+		t.Fatalf("https status = %d, want 200", w.Code)
+	}
+}
+
+func TestSecureAllowedHosts(t *testing.T) {
+	r := New()
+	r.Use(Secure(SecureConfig{AllowedHosts: []string{"example.com"}}))
+	r.GET("/", func(c *Context) {})
+
+	if w := r.Perform(httptest.NewRequest("GET", "http://example.com:8080/", nil)); w.Code != 200 {
+		t.Fatalf("allowed host status = %d", w.Code)
+	}
+	if w := r.Perform(httptest.NewRequest("GET", "http://evil.com/", nil)); w.Code != 400 {
+		t.Fatalf("other host status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,166 @@
+package gee
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span is one traced request, its ids are lower case hex as in the W3C traceparent header
+type Span struct {
+	TraceID      string            `json:"traceId"`
+	SpanID       string            `json:"spanId"`
+	ParentSpanID string            `json:"parentSpanId,omitempty"`
+	TraceState   string            `json:"traceState,omitempty"`
+	Sampled      bool              `json:"sampled"`
+	Name         string            `json:"name"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+// SpanExporter receives every finished and sampled span
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// JSONExporter writes every span as one JSON line
+type JSONExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{w: w}
+}
+
+// StdoutExporter writes spans as JSON lines to stdout
+func StdoutExporter() *JSONExporter {
+	return NewJSONExporter(os.Stdout)
+}
+
+func (e *JSONExporter) ExportSpan(span *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_ = json.NewEncoder(e.w).Encode(span)
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the span of the request, ctx is usually c.Req.Context()
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// TraceParent returns the traceparent value naming span as the parent of a downstream call
+func (span *Span) TraceParent() string {
+	flags := "00"
+	if span.Sampled {
+		flags = "01"
+	}
+	return "00-" + span.TraceID + "-" + span.SpanID + "-" + flags
+}
+
+// InjectTrace sets traceparent and tracestate on the header of an outgoing request,
+// so the downstream service continues the trace of ctx
+func InjectTrace(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	header.Set("traceparent", span.TraceParent())
+	if span.TraceState != "" {
+		header.Set("tracestate", span.TraceState)
+	}
+}
+
+// Tracing opens a span for every request, continuing the trace of a valid
+// traceparent header. The span is named after the route pattern, not the raw
+// path, and is available through SpanFromContext(c.Req.Context())
+func Tracing(exporter SpanExporter) HandlerFunc {
+	return func(c *Context) {
+		span := &Span{
+			SpanID:  randomHex(8),
+			Sampled: true,
+			Start:   time.Now(),
+		}
+		if traceID, parentID, sampled, ok := parseTraceParent(c.Req.Header.Get("traceparent")); ok {
+			span.TraceID = traceID
+			span.ParentSpanID = parentID
+			span.Sampled = sampled
+			span.TraceState = c.Req.Header.Get("tracestate")
+		} else {
+			span.TraceID = randomHex(16)
+		}
+
+		c.Req = c.Req.WithContext(context.WithValue(c.Req.Context(), spanKey{}, span))
+		c.Next()
+
+		span.End = time.Now()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		span.Name = c.Method + " " + route
+		span.Attributes = map[string]string{
+			"http.method":      c.Method,
+			"http.route":       c.FullPath(),
+			"http.status_code": strconv.Itoa(c.StatusCode),
+		}
+		if id := c.RequestID(); id != "" {
+			span.Attributes["http.request_id"] = id
+		}
+		if span.Sampled && exporter != nil {
+			exporter.ExportSpan(span)
+		}
+	}
+}
+
+// parseTraceParent parses version 00 of the header, eg.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+// Unknown higher versions are read by the version 00 layout as the spec asks
+func parseTraceParent(header string) (traceID, parentID string, sampled bool, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return
+	}
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return
+	}
+	if !isLowerHex(traceID, 32) || !isLowerHex(parentID, 16) || !isLowerHex(flags, 2) {
+		return
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(parentID, "0") == "" {
+		return
+	}
+	b, _ := hex.DecodeString(flags)
+	return traceID, parentID, b[0]&1 == 1, true
+}
+
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !(s[i] >= '0' && s[i] <= '9' || s[i] >= 'a' && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,103 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type recordExporter struct {
+	spans []*Span
+}
+
+func (e *recordExporter) ExportSpan(span *Span) {
+	e.spans = append(e.spans, span)
+}
+
+func TestRequestID(t *testing.T) {
+	r := New()
+	r.Use(RequestID())
+	var got string
+	r.GET("/", func(c *Context) {
+		got = c.RequestID()
+	})
+
+	w := r.Perform(httptest.NewRequest("GET", "/", nil))
+	if len(got) != 32 || w.Header().Get(HeaderRequestID) != got {
+		t.Fatalf("generated id %q, header %q", got, w.Header().Get(HeaderRequestID))
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(HeaderRequestID, "abc-123")
+	r.Perform(req)
+	if got != "abc-123" {
+		t.Fatalf("id = %q, want abc-123", got)
+	}
+}
+
+func TestTracingContinuesTrace(t *testing.T) {
+	exporter := &recordExporter{}
+	r := New()
+	r.Use(Tracing(exporter))
+	var outgoing http.Header
+	r.GET("/users/:id", func(c *Context) {
+		outgoing = http.Header{}
+		InjectTrace(c.Req.Context(), outgoing)
+		c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest("GET", "/users/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("tracestate", "congo=t61rcWkgMzE")
+	r.Perform(req)
+
+	if len(exporter.spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(exporter.spans))
+	}
+	span := exporter.spans[0]
+	if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentSpanID != "00f067aa0ba902b7" {
+		t.Fatalf("trace not continued: %+v", span)
+	}
+	if span.Name != "GET /users/:id" || span.Attributes["http.status_code"] != "200" {
+		t.Fatalf("unexpected span %+v", span)
+	}
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.SpanID + "-01"
+	if outgoing.Get("traceparent") != want || outgoing.Get("tracestate") != "congo=t61rcWkgMzE" {
+		t.Fatalf("propagated %v, want traceparent %s", outgoing, want)
+	}
+}
+
+func TestTracingInvalidParent(t *testing.T) {
+	buf := new(bytes.Buffer)
+	r := New()
+	r.Use(Tracing(NewJSONExporter(buf)))
+	r.GET("/", func(c *Context) {})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	r.Perform(req)
+
+	var span Span
+	if err := json.Unmarshal(buf.Bytes(), &span); err != nil {
+		t.Fatal(err)
+	}
+	if span.ParentSpanID != "" || len(span.TraceID) != 32 || span.TraceID == "00000000000000000000000000000000" {
+		t.Fatalf("invalid parent should start a new trace: %+v", span)
+	}
+}
+
+func TestTracingNotSampled(t *testing.T) {
+	exporter := &recordExporter{}
+	r := New()
+	r.Use(Tracing(exporter))
+	r.GET("/", func(c *Context) {})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	r.Perform(req)
+	if len(exporter.spans) != 0 {
+		t.Fatal("unsampled span should not be exported")
+	}
+}
@@ -1,74 +1,205 @@
 package gee
 
-import "strings"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
 
 // use trie tree to realize dynamic router
 
 type node struct {
-	pattern  string  // the router wait for match
-	part     string  // part of router
-	children []*node // current node's children node
-	isWild   bool    // whether it is an accurate match, if current part is :filename or *filename, then isWild = true
+	pattern    string         // the router wait for match
+	part       string         // part of router
+	children   []*node        // current node's children node
+	isWild     bool           // whether it is an accurate match, if current part is :filename or *filename, then isWild = true
+	constraint *regexp.Regexp // the value of a :param<constraint> part must match it
+	handlers   []HandlerFunc  // route middlewares followed by the handler, set with pattern
 }
 
-// get the first matching node
+// constraints are the named constraints usable as :param<name>,
+// any other constraint is taken as a regular expression
+var constraints = map[string]string{
+	"int":   `-?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"alpha": `[A-Za-z]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+// splitParam splits :id<int> into the name id and the constraint int
+func splitParam(part string) (name string, constraint string) {
+	name = part[1:]
+	if i := strings.IndexByte(name, '<'); i >= 0 && strings.HasSuffix(name, ">") {
+		return name[:i], name[i+1 : len(name)-1]
+	}
+	return name, ""
+}
+
+// compileConstraint anchors the constraint, so it has to match the whole part
+func compileConstraint(constraint string) *regexp.Regexp {
+	expr, ok := constraints[constraint]
+	if !ok {
+		expr = constraint
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		panic(fmt.Sprintf("gee: invalid route constraint <%s>: %v", constraint, err))
+	}
+	return re
+}
+
+// accept reports whether the search part can be matched by this node,
+// with fold static parts are compared case-insensitively
+func (n *node) accept(part string, fold bool) bool {
+	if !n.isWild {
+		return n.part == part || fold && strings.EqualFold(n.part, part)
+	}
+	return n.constraint == nil || n.constraint.MatchString(part)
+}
+
+// get the child registered for exactly this part
 func (n *node) matchChild(part string) *node {
 	for _, child := range n.children {
-		if child.part == part || child.isWild {
+		if child.part == part {
 			return child
 		}
 	}
 	return nil
 }
 
-// get all the matching nodes
-func (n *node) matchChildren(part string) []*node {
+// get all the matching nodes, static children come first, then constrained
+// params, then plain params and wildcards, so the most specific route wins
+func (n *node) matchChildren(part string, fold bool) []*node {
 	nodes := make([]*node, 0)
 	for _, child := range n.children {
-		if child.part == part || child.isWild {
+		if !child.isWild && child.accept(part, fold) {
+			nodes = append(nodes, child)
+		}
+	}
+	for _, child := range n.children {
+		if child.isWild && child.constraint != nil && child.accept(part, fold) {
+			nodes = append(nodes, child)
+		}
+	}
+	for _, child := range n.children {
+		if child.isWild && child.constraint == nil && child.part[0] == ':' {
+			nodes = append(nodes, child)
+		}
+	}
+	for _, child := range n.children {
+		if child.part[0] == '*' {
 			nodes = append(nodes, child)
 		}
 	}
 	return nodes
 }
 
+func newNode(part string) *node {
+	// if the first char of the part is ':', that means it is a dynamic router
+	n := &node{part: part, isWild: part[0] == ':' || part[0] == '*'}
+	if part[0] == ':' {
+		if _, constraint := splitParam(part); constraint != "" {
+			n.constraint = compileConstraint(constraint)
+		}
+	}
+	return n
+}
+
 // use iteration to register a new router
-func (n *node) insert(pattern string, parts []string, height int) {
-	if len(parts) == height{
+func (n *node) insert(pattern string, parts []string, height int, handlers []HandlerFunc) {
+	if len(parts) == height {
 		n.pattern = pattern
+		n.handlers = handlers
 		return
 	}
 
 	part := parts[height]
 	child := n.matchChild(part)
-
-	// if the first char of the part is ':', that means it is a dynamic router
-	if child == nil{
-		child = &node{part: part, isWild: part[0] == ':' || part[0] == '*'}
+	if child == nil {
+		child = newNode(part)
 		n.children = append(n.children, child)
 	}
 
-	child.insert(pattern, parts, height + 1)
+	child.insert(pattern, parts, height+1, handlers)
+}
+
+// insertCopy is insert for a published tree, it returns a copy of n where only
+// the nodes on the path of the pattern are copied and the other subtrees are shared
+func (n *node) insertCopy(pattern string, parts []string, height int, handlers []HandlerFunc) *node {
+	c := *n
+	if len(parts) == height {
+		c.pattern = pattern
+		c.handlers = handlers
+		return &c
+	}
+
+	part := parts[height]
+	c.children = make([]*node, len(n.children), len(n.children)+1)
+	copy(c.children, n.children)
+	for i, child := range c.children {
+		if child.part == part {
+			c.children[i] = child.insertCopy(pattern, parts, height+1, handlers)
+			return &c
+		}
+	}
+	c.children = append(c.children, newNode(part).insertCopy(pattern, parts, height+1, handlers))
+	return &c
+}
+
+// removeCopy returns a copy of n without the route of pattern, nil if nothing is
+// left of n. Only the nodes on the path are copied, it reports whether the route existed
+func (n *node) removeCopy(pattern string, parts []string, height int) (*node, bool) {
+	c := *n
+	if len(parts) == height {
+		if n.pattern != pattern {
+			return n, false
+		}
+		c.pattern = ""
+		c.handlers = nil
+	} else {
+		i := -1
+		for j, child := range n.children {
+			if child.part == parts[height] {
+				i = j
+			}
+		}
+		if i < 0 {
+			return n, false
+		}
+		child, ok := n.children[i].removeCopy(pattern, parts, height+1)
+		if !ok {
+			return n, false
+		}
+		c.children = make([]*node, 0, len(n.children))
+		c.children = append(c.children, n.children[:i]...)
+		if child != nil {
+			c.children = append(c.children, child)
+		}
+		c.children = append(c.children, n.children[i+1:]...)
+	}
+	if c.pattern == "" && len(c.children) == 0 {
+		return nil, true
+	}
+	return &c, true
 }
 
 // use iteration to get the router result
-func (n* node) search(parts []string, height int) *node{
-	if len(parts) == height || strings.HasPrefix(n.part, "*"){
-		if n.pattern == ""{
+func (n *node) search(parts []string, height int, fold bool) *node {
+	if len(parts) == height || strings.HasPrefix(n.part, "*") {
+		if n.pattern == "" {
 			return nil
 		}
 		return n
 	}
 
 	part := parts[height]
-	children := n.matchChildren(part)
+	children := n.matchChildren(part, fold)
 
-	for _, child := range children{
-		result := child.search(parts, height + 1)
-		if result != nil{
+	for _, child := range children {
+		result := child.search(parts, height+1, fold)
+		if result != nil {
 			return result
 		}
 	}
 	return nil
 }
-
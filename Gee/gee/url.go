@@ -0,0 +1,51 @@
+package gee
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// URL builds the path of the named route, params fill its :param and *wildcard
+// parts in order and are escaped, a wildcard value keeps its slashes
+func (engine *Engine) URL(name string, params ...interface{}) (string, error) {
+	engine.mu.RLock()
+	route, ok := engine.namedRoutes[name]
+	engine.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("gee: no route named %q", name)
+	}
+
+	segments := strings.Split(route.Pattern, "/")
+	next := 0
+	for i, seg := range segments {
+		if seg == "" || (seg[0] != ':' && seg[0] != '*') {
+			continue
+		}
+		if next == len(params) {
+			return "", fmt.Errorf("gee: route %q needs a value for %s", name, seg)
+		}
+		value := fmt.Sprint(params[next])
+		next++
+
+		if seg[0] == ':' {
+			if param, constraint := splitParam(seg); constraint != "" && !compileConstraint(constraint).MatchString(value) {
+				return "", fmt.Errorf("gee: route %q: %q does not match :%s<%s>", name, value, param, constraint)
+			}
+			segments[i] = url.PathEscape(value)
+			continue
+		}
+		parts := strings.Split(strings.TrimPrefix(value, "/"), "/")
+		for j := range parts {
+			parts[j] = url.PathEscape(parts[j])
+		}
+		segments[i] = strings.Join(parts, "/")
+		// only one * is allowed, the rest of the pattern is ignored like in parsePattern
+		segments = segments[:i+1]
+		break
+	}
+	if next != len(params) {
+		return "", fmt.Errorf("gee: route %q takes %d values, got %d", name, next, len(params))
+	}
+	return strings.Join(segments, "/"), nil
+}
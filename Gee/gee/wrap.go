@@ -0,0 +1,46 @@
+package gee
+
+import "net/http"
+
+// WrapH turns a http.Handler into a HandlerFunc, eg. r.GET("/debug/vars", gee.WrapH(expvar.Handler()))
+func WrapH(h http.Handler) HandlerFunc {
+	return func(c *Context) {
+		h.ServeHTTP(c.Writer, c.Req)
+	}
+}
+
+// WrapF turns a http.HandlerFunc into a HandlerFunc, eg. r.GET("/debug/pprof/*name", gee.WrapF(pprof.Index))
+func WrapF(f http.HandlerFunc) HandlerFunc {
+	return func(c *Context) {
+		f(c.Writer, c.Req)
+	}
+}
+
+// UseHTTP adds standard net/http middlewares to the group. The rest of the
+// chain runs as the next handler of the middleware, with the request and
+// writer it passes on, so a request carrying a new context is seen by the
+// later handlers. If the middleware does not call next, the chain is aborted
+func (group *RouterGroup) UseHTTP(middlewares ...func(http.Handler) http.Handler) {
+	for _, middleware := range middlewares {
+		group.Use(adaptHTTP(middleware))
+	}
+}
+
+func adaptHTTP(middleware func(http.Handler) http.Handler) HandlerFunc {
+	return func(c *Context) {
+		called := false
+		writer := c.Writer
+		next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			called = true
+			c.Writer = w
+			c.Req = req
+			c.Next()
+		})
+		middleware(next).ServeHTTP(c.Writer, c.Req)
+		// the writer of the middleware may be finished, eg. a closed gzip writer
+		c.Writer = writer
+		if !called {
+			c.Abort()
+		}
+	}
+}
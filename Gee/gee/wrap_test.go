@@ -0,0 +1,62 @@
+package gee
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type ctxKey struct{}
+
+func TestWrapHAndWrapF(t *testing.T) {
+	r := New()
+	r.GET("/h", WrapH(http.NotFoundHandler()))
+	r.GET("/f", WrapF(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("f " + req.URL.Path))
+	}))
+
+	if w := r.Perform(httptest.NewRequest("GET", "/h", nil)); w.Code != 404 {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+	if w := r.Perform(httptest.NewRequest("GET", "/f", nil)); w.Body.String() != "f /f" {
+		t.Fatalf("body = %q", w.Body.String())
+	}
+}
+
+func TestUseHTTPKeepsRequest(t *testing.T) {
+	r := New()
+	r.UseHTTP(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("X-Std", "1")
+			ctx := context.WithValue(req.Context(), ctxKey{}, "from std")
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	})
+	r.GET("/", func(c *Context) {
+		c.String(200, "%v", c.Req.Context().Value(ctxKey{}))
+	})
+
+	w := r.Perform(httptest.NewRequest("GET", "/", nil))
+	if w.Body.String() != "from std" || w.Header().Get("X-Std") != "1" {
+		t.Fatalf("got %q %v", w.Body.String(), w.Header())
+	}
+}
+
+func TestUseHTTPShortCircuit(t *testing.T) {
+	r := New()
+	r.UseHTTP(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+		})
+	})
+	called := false
+	r.GET("/", func(c *Context) {
+		called = true
+	})
+
+	w := r.Perform(httptest.NewRequest("GET", "/", nil))
+	if called || w.Code != 403 {
+		t.Fatalf("called %v, status %d", called, w.Code)
+	}
+}